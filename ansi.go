@@ -0,0 +1,305 @@
+package markydown
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ANSICapabilities tells which features of ANSI terminals an ANSIRenderer can
+// use.
+type ANSICapabilities struct {
+	// Colors tells if the terminal supports colors. If false, headings are
+	// just bold.
+	Colors bool
+
+	// Italic tells if the terminal supports italics. If false, emphasized
+	// text is underlined instead.
+	Italic bool
+
+	// Hyperlinks tells if the terminal supports OSC 8 hyperlinks. If false,
+	// link targets are shown after the link text, like in `text <target>`.
+	Hyperlinks bool
+}
+
+// DetectANSICapabilities guesses the capabilities of the terminal we are
+// running in, based on environment variables like TERM, COLORTERM and
+// NO_COLOR.
+//
+// This doesn't check if the output actually goes to a terminal; that's up to
+// the caller.
+func DetectANSICapabilities() ANSICapabilities {
+	return detectANSICapabilities(os.Getenv)
+}
+
+// detectANSICapabilities guesses the terminal capabilities based on the
+// environment variables returned by getenv.
+func detectANSICapabilities(getenv func(string) string) ANSICapabilities {
+	term := getenv("TERM")
+	if term == "dumb" {
+		return ANSICapabilities{}
+	}
+
+	program := getenv("TERM_PROGRAM")
+	vte, _ := strconv.Atoi(getenv("VTE_VERSION"))
+
+	hasTerm := func(names ...string) bool {
+		for _, name := range names {
+			if strings.Contains(term, name) { // as in xterm-kitty
+				return true
+			}
+		}
+		return false
+	}
+
+	caps := ANSICapabilities{
+		Colors: getenv("NO_COLOR") == "" &&
+			(getenv("COLORTERM") != "" || strings.Contains(term, "color") ||
+				hasTerm("xterm", "screen", "tmux", "rxvt", "linux", "alacritty", "kitty", "foot")),
+
+		Italic: hasTerm("xterm", "tmux", "rxvt", "alacritty", "kitty", "foot") ||
+			program == "iTerm.app" || program == "WezTerm" || program == "vscode",
+
+		Hyperlinks: hasTerm("alacritty", "kitty", "foot") || vte >= 5000 ||
+			program == "iTerm.app" || program == "WezTerm" || program == "vscode" ||
+			getenv("WT_SESSION") != "" || getenv("KONSOLE_VERSION") != "",
+	}
+
+	return caps
+}
+
+// ANSIRenderer is a Processor that renders a Markydown document as text for
+// ANSI terminals, writing the results to an io.Writer. This is intended for
+// things like rich help screens in command-line tools.
+//
+// The text is laid out just like the TextRenderer does it, but uses ANSI
+// escape sequences to render strongly emphasized text in bold, emphasized
+// text in italics (or underlined, if the terminal doesn't support italics),
+// headings in bold colors, and links as OSC 8 hyperlinks (or as
+// `text <target>`, if the terminal doesn't support them). See
+// ANSICapabilities. Control characters in the document are replaced with
+// U+FFFD, so that documents can't send escape sequences of their own.
+type ANSIRenderer struct {
+	// Width is the maximum line width, in characters. Words longer than this
+	// are not broken, so they may exceed it. Zero (or less) disables wrapping.
+	Width int
+
+	// Capabilities are the terminal capabilities the renderer can use.
+	Capabilities ANSICapabilities
+
+	text TextRenderer // Does the actual work
+}
+
+// NewANSIRenderer creates a new ANSIRenderer that writes its output to w,
+// using the given terminal capabilities and wrapping lines at 80 columns. Use
+// DetectANSICapabilities to guess the capabilities of the current terminal.
+func NewANSIRenderer(w io.Writer, capabilities ANSICapabilities) *ANSIRenderer {
+	return &ANSIRenderer{
+		Width:        80,
+		Capabilities: capabilities,
+		text:         TextRenderer{w: w},
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *ANSIRenderer) Err() error {
+	return r.text.Err()
+}
+
+// StartDocument implements the Processor interface.
+func (r *ANSIRenderer) StartDocument() {
+	r.text.Width = r.Width
+	caps := r.Capabilities
+	r.text.ansi = &caps
+	r.text.StartDocument()
+}
+
+// EndDocument implements the Processor interface.
+func (r *ANSIRenderer) EndDocument() {
+	r.text.EndDocument()
+}
+
+// StartParagraph implements the Processor interface.
+func (r *ANSIRenderer) StartParagraph(parType ParType) {
+	r.text.StartParagraph(parType)
+}
+
+// EndParagraph implements the Processor interface.
+func (r *ANSIRenderer) EndParagraph(parType ParType) {
+	r.text.EndParagraph(parType)
+}
+
+// Fragment implements the Processor interface.
+func (r *ANSIRenderer) Fragment(text string) {
+	r.text.Fragment(text)
+}
+
+// SpecialToken implements the Processor interface.
+func (r *ANSIRenderer) SpecialToken(token SpecialToken) {
+	r.text.SpecialToken(token)
+}
+
+// ChangeTextStyle implements the Processor interface.
+func (r *ANSIRenderer) ChangeTextStyle(style TextStyle) {
+	r.text.ChangeTextStyle(style)
+}
+
+// StartLink implements the Processor interface.
+func (r *ANSIRenderer) StartLink(target string) {
+	r.text.StartLink(target)
+}
+
+// EndLink implements the Processor interface.
+func (r *ANSIRenderer) EndLink() {
+	r.text.EndLink()
+}
+
+// StartList implements the ListProcessor interface.
+func (r *ANSIRenderer) StartList(list ListInfo) {
+	r.text.StartList(list)
+}
+
+// EndList implements the ListProcessor interface.
+func (r *ANSIRenderer) EndList(list ListInfo) {
+	r.text.EndList(list)
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *ANSIRenderer) StartListItem(list ListInfo) {
+	r.text.StartListItem(list)
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *ANSIRenderer) EndListItem(list ListInfo) {
+	r.text.EndListItem(list)
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *ANSIRenderer) StartQuote() {
+	r.text.StartQuote()
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *ANSIRenderer) EndQuote() {
+	r.text.EndQuote()
+}
+
+// ANSI escape sequences used by the ANSIRenderer.
+const (
+	ansiReset         = "\x1b[0m"
+	ansiHyperlinkEnd  = "\x1b]8;;\x1b\\"
+	ansiBold          = "1"
+	ansiItalic        = "3"
+	ansiUnderline     = "4"
+	ansiHeadingColor1 = "35" // Magenta
+	ansiHeadingColor2 = "36" // Cyan
+	ansiHeadingColor3 = "33" // Yellow
+)
+
+// formatLine returns the text of a line of a paragraph of a given type, with
+// all the escape sequences needed to render it.
+//
+// Styles and hyperlinks are closed at the end of every line, so that the
+// indentation of the next line doesn't get underlined or linked.
+func (caps *ANSICapabilities) formatLine(line []textWord, parType ParType) string {
+	var b strings.Builder
+	sgr := ""  // Current SGR parameters
+	link := "" // Current hyperlink target
+
+	set := func(newSGR, newLink string) {
+		if newLink != link {
+			if link != "" {
+				b.WriteString(ansiHyperlinkEnd)
+			}
+			if newLink != "" {
+				b.WriteString("\x1b]8;;" + escapeHyperlinkTarget(newLink) + "\x1b\\")
+			}
+			link = newLink
+		}
+
+		if newSGR != sgr {
+			if sgr != "" {
+				b.WriteString(ansiReset)
+			}
+			if newSGR != "" {
+				b.WriteString("\x1b[" + newSGR + "m")
+			}
+			sgr = newSGR
+		}
+	}
+
+	for i, word := range line {
+		if i > 0 {
+			// The space between words gets only what is common to both sides
+			prev := line[i-1][len(line[i-1])-1]
+			next := word[0]
+			spaceLink := ""
+			if prev.link == next.link {
+				spaceLink = prev.link
+			}
+			set(caps.sgr(prev.style&next.style, parType), spaceLink)
+			b.WriteString(" ")
+		}
+
+		for _, run := range word {
+			set(caps.sgr(run.style, parType), run.link)
+			b.WriteString(run.text)
+		}
+	}
+
+	set("", "")
+
+	return b.String()
+}
+
+// escapeHyperlinkTarget percent-encodes the bytes of a link target that are
+// not printable ASCII characters, so that they can't end the OSC 8 sequence the
+// target is written in (and start another escape sequence).
+func escapeHyperlinkTarget(target string) string {
+	var b strings.Builder
+	for i := 0; i < len(target); i++ {
+		c := target[i]
+		if c < 0x21 || c > 0x7e {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// sgr returns the SGR ("Select Graphic Rendition") parameters used to render
+// text in a given style, within a paragraph of a given type.
+func (caps *ANSICapabilities) sgr(style TextStyle, parType ParType) string {
+	var params []string
+
+	level := parType.HeadingLevel()
+
+	if style.Has(TextStyleStrong) || level > 0 {
+		params = append(params, ansiBold)
+	}
+
+	if style.Has(TextStyleEmphasis) {
+		if caps.Italic {
+			params = append(params, ansiItalic)
+		} else {
+			params = append(params, ansiUnderline)
+		}
+	}
+
+	if caps.Colors {
+		switch level {
+		case 1:
+			params = append(params, ansiHeadingColor1)
+		case 2:
+			params = append(params, ansiHeadingColor2)
+		case 3:
+			params = append(params, ansiHeadingColor3)
+		}
+	}
+
+	return strings.Join(params, ";")
+}
@@ -0,0 +1,101 @@
+package markydown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// renderANSI renders a Markydown document for an ANSI terminal with given
+// capabilities, wrapped at 16 columns.
+func renderANSI(input string, caps ANSICapabilities) string {
+	var buf bytes.Buffer
+	r := NewANSIRenderer(&buf, caps)
+	r.Width = 16
+	Parse(input, r)
+	return buf.String()
+}
+
+// Tests rendering for terminals with all capabilities.
+func TestANSIRenderer(t *testing.T) {
+	caps := ANSICapabilities{Colors: true, Italic: true, Hyperlinks: true}
+
+	testData := map[string]string{
+		"": "",
+
+		// Styles
+		"Some **strong** text": "Some \x1b[1mstrong\x1b[0m text\n",
+		"*Both **styles***":    "\x1b[3mBoth \x1b[0m\x1b[1;3mstyles\x1b[0m\n",
+		"Un*believ*able":       "Un\x1b[3mbeliev\x1b[0mable\n",
+
+		// Styles are closed at the end of each line
+		"*Emphasis across lines*": "\x1b[3mEmphasis across\x1b[0m\n\x1b[3mlines\x1b[0m\n",
+
+		// Headings
+		"# One\n\n## Two\n\n#### Four": "\x1b[1;35mOne\x1b[0m\n\n\x1b[1;36mTwo\x1b[0m\n\n\x1b[1mFour\x1b[0m\n",
+
+		// Links
+		"A [link](http://x.com)!": "A \x1b]8;;http://x.com\x1b\\link\x1b]8;;\x1b\\!\n",
+		"[Two words](x)":          "\x1b]8;;x\x1b\\Two words\x1b]8;;\x1b\\\n",
+
+		// Link targets can't end the escape sequence they are written in
+		"[Evil](x\x1b]8;;y\x07 é)": "\x1b]8;;x%1B]8;;y%07%20%C3%A9\x1b\\Evil\x1b]8;;\x1b\\\n",
+
+		// Control characters in the document don't reach the terminal
+		"a\x1b[31mred `\x9b2J`":          "a\ufffd[31mred \ufffd2J\n",
+		"![\x07](x) [a](\x1b)":           "\ufffd \x1b]8;;%1B\x1b\\a\x1b]8;;\x1b\\\n",
+		"```\n\tOK\x1b]0;title\x07\n```": "    \tOK\ufffd]0;title\ufffd\n",
+
+		// Lists
+		"+ One *two* three": "- One \x1b[3mtwo\x1b[0m three\n",
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, renderANSI(input, caps), expected)
+	}
+}
+
+// Tests rendering for terminals with limited capabilities.
+func TestANSIRendererFallbacks(t *testing.T) {
+	testData := map[string]string{
+		"# *Title*":               "\x1b[1;4mTitle\x1b[0m\n",
+		"Some *emphasis*":         "Some \x1b[4memphasis\x1b[0m\n",
+		"A [link](http://x.com)!": "A link\n<http://x.com>!\n",
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, renderANSI(input, ANSICapabilities{}), expected)
+	}
+}
+
+// Tests guessing the terminal capabilities from environment variables.
+func TestDetectANSICapabilities(t *testing.T) {
+	testData := []struct {
+		env      map[string]string
+		expected ANSICapabilities
+	}{
+		{map[string]string{}, ANSICapabilities{}},
+		{map[string]string{"TERM": "dumb", "COLORTERM": "truecolor"}, ANSICapabilities{}},
+		{map[string]string{"TERM": "xterm-256color"}, ANSICapabilities{Colors: true, Italic: true}},
+		{map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"}, ANSICapabilities{Italic: true}},
+		{map[string]string{"TERM": "screen"}, ANSICapabilities{Colors: true}},
+		{map[string]string{"TERM": "xterm-256color", "VTE_VERSION": "6003"},
+			ANSICapabilities{Colors: true, Italic: true, Hyperlinks: true}},
+		{map[string]string{"TERM": "xterm-kitty"}, ANSICapabilities{Colors: true, Italic: true, Hyperlinks: true}},
+		{map[string]string{"TERM": "kitty"}, ANSICapabilities{Colors: true, Italic: true, Hyperlinks: true}},
+	}
+
+	for _, td := range testData {
+		env := td.env
+		caps := detectANSICapabilities(func(name string) string { return env[name] })
+		assert.Equal(t, caps, td.expected)
+	}
+}
+
+// Tests if write errors are reported.
+func TestANSIRendererError(t *testing.T) {
+	r := NewANSIRenderer(failingWriter{}, ANSICapabilities{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
@@ -22,6 +22,18 @@ func isBullet(r rune) bool {
 	return r == '+'
 }
 
+// isDigit checks if a given rune is a decimal digit, as used to number items in
+// ordered lists.
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// isOrderedListDelimiter checks if a given rune can be used after the number of
+// an ordered list item.
+func isOrderedListDelimiter(r rune) bool {
+	return r == '.'
+}
+
 // isEmphasis checks if a given rune can be used to emphasize text.
 func isEmphasis(r rune) bool {
 	return r == '*'
@@ -32,6 +44,17 @@ func isEscape(r rune) bool {
 	return r == '\\'
 }
 
+// isCodeDelimiter checks if a given rune can be used to delimit code spans.
+func isCodeDelimiter(r rune) bool {
+	return r == '`'
+}
+
+// isImageStart checks if a given rune can be used (right before a `[`) to start
+// an image.
+func isImageStart(r rune) bool {
+	return r == '!'
+}
+
 // isLinkStart checks if a given rune can be used to start a link.
 func isLinkStart(r rune) bool {
 	return r == '['
@@ -51,3 +74,9 @@ func isLinkTargetStart(r rune) bool {
 func isLinkTargetEnd(r rune) bool {
 	return r == ')'
 }
+
+// isQuoteMarker checks if a given rune can be used to mark lines of block
+// quotes.
+func isQuoteMarker(r rune) bool {
+	return r == '>'
+}
@@ -0,0 +1,214 @@
+// Command markydown converts Markydown documents to other formats.
+//
+// Usage:
+//
+//	markydown [flags] [file ...]
+//
+// The input files are concatenated (as if separated by a blank line) and
+// converted as a single document. If no files are given, the document is read
+// from the standard input.
+//
+// The exit status is 0 if the conversion succeeded, and 2 if the command line
+// was bad or reading or writing failed. (This is the same convention used by
+// markylint, which also exits with 1 when it finds problems.)
+//
+// The flags are:
+//
+//	-to format
+//		The output format: "html" (the default), "text" (plain text),
+//		"ansi" (text for ANSI terminals, using the capabilities detected from
+//		the environment), "md" (Markydown itself, formatted canonically),
+//		"commonmark" (CommonMark Markdown), "json" (the sequence of parsing
+//		events, as JSON lines), "pandoc" (a Pandoc JSON AST) or "latex".
+//	-full
+//		Generate a full document (like an HTML document with `<html>` and
+//		`<body>` elements, or a LaTeX document with a preamble), instead of
+//		just a fragment that can be included in another document.
+//	-o file
+//		Write the output to file instead of to the standard output.
+//	-width n
+//		The width to wrap plain text, ANSI text and Markydown at (the default
+//		is 80; zero disables wrapping).
+//	-max-heading-level n
+//		The maximum heading level recognized (from 1 to 6; the default is
+//		6). Deeper headings are parsed as regular text.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	markydown "github.com/lmbarros/sbxs_go_markydown"
+)
+
+// Exit statuses.
+const (
+	exitOK    = 0 // Conversion succeeded
+	exitError = 2 // Bad command line, or reading or writing failed
+)
+
+// renderer is a Processor that renders a document to some output format.
+type renderer interface {
+	markydown.Processor
+
+	// Err returns the first error that happened while writing the output.
+	Err() error
+}
+
+// renderOptions are the command-line options that affect the renderers.
+type renderOptions struct {
+	full  bool // Generate a full document?
+	width int  // Width to wrap text at
+}
+
+// formats maps the supported output format names to functions creating the
+// renderers for them. The renderers write to w.
+var formats = map[string]func(w io.Writer, opts renderOptions) renderer{
+	"html": func(w io.Writer, opts renderOptions) renderer {
+		r := markydown.NewHTMLRenderer(w)
+		r.FullDocument = opts.full
+		return r
+	},
+	"text": func(w io.Writer, opts renderOptions) renderer {
+		r := markydown.NewTextRenderer(w)
+		r.Width = opts.width
+		return r
+	},
+	"ansi": func(w io.Writer, opts renderOptions) renderer {
+		r := markydown.NewANSIRenderer(w, markydown.DetectANSICapabilities())
+		r.Width = opts.width
+		return r
+	},
+	"commonmark": func(w io.Writer, opts renderOptions) renderer {
+		return markydown.NewCommonMarkRenderer(w)
+	},
+	"json": func(w io.Writer, opts renderOptions) renderer {
+		return markydown.NewJSONRenderer(w)
+	},
+	"latex": func(w io.Writer, opts renderOptions) renderer {
+		r := markydown.NewLaTeXRenderer(w)
+		r.FullDocument = opts.full
+		return r
+	},
+	"md": func(w io.Writer, opts renderOptions) renderer {
+		r := markydown.NewMarkydownRenderer(w)
+		r.Width = opts.width
+		return r
+	},
+	"pandoc": func(w io.Writer, opts renderOptions) renderer {
+		return markydown.NewPandocRenderer(w)
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs markydown with the given command-line arguments (excluding the
+// program name) and standard streams, and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("markydown", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	to := flags.String("to", "html", "output `format`: "+strings.Join(formatNames(), ", "))
+	full := flags.Bool("full", false, "generate a full document instead of a fragment")
+	outName := flags.String("o", "", "write the output to `file` instead of to the standard output")
+	width := flags.Int("width", 80, "width to wrap plain text, ANSI text and Markydown at (0 disables wrapping)")
+	maxHeadingLevel := flags.Int("max-heading-level", 6, "maximum heading level recognized")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: markydown [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	newRenderer, ok := formats[*to]
+	if !ok {
+		fmt.Fprintf(stderr, "markydown: unknown output format %q\n", *to)
+		return exitError
+	}
+
+	in, closeInputs, err := openInputs(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "markydown: %v\n", err)
+		return exitError
+	}
+	defer closeInputs()
+
+	out := stdout
+	var outFile *os.File
+	if *outName != "" {
+		outFile, err = os.Create(*outName)
+		if err != nil {
+			fmt.Fprintf(stderr, "markydown: %v\n", err)
+			return exitError
+		}
+		out = outFile
+	}
+
+	r := newRenderer(out, renderOptions{full: *full, width: *width})
+	err = markydown.ParseReader(in, r, markydown.MaxHeadingLevel(*maxHeadingLevel))
+	if err == nil {
+		err = r.Err()
+	}
+	if outFile != nil {
+		if closeErr := outFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "markydown: %v\n", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// openInputs opens the input files with the given names, and returns a reader
+// that reads all of them, as if separated by blank lines, along with a function
+// that closes them all. If there are no names, the input is read from stdin.
+func openInputs(names []string, stdin io.Reader) (io.Reader, func(), error) {
+	if len(names) == 0 {
+		return stdin, func() {}, nil
+	}
+
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	var readers []io.Reader
+	for i, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, f)
+
+		if i > 0 {
+			readers = append(readers, strings.NewReader("\n\n"))
+		}
+		readers = append(readers, f)
+	}
+
+	return io.MultiReader(readers...), closeAll, nil
+}
+
+// formatNames returns the names of the supported output formats, sorted.
+func formatNames() []string {
+	var names []string
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// runMarkydown runs markydown with some arguments and standard input, and
+// returns its exit status and outputs.
+func runMarkydown(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+// Tests converting from the standard input to the standard output.
+func TestMarkydownStdin(t *testing.T) {
+	status, stdout, stderr := runMarkydown(nil, "# Hello\n\n*World*")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "<h1>Hello</h1>\n<p><em>World</em></p>\n")
+	assert.Equal(t, stderr, "")
+
+	status, stdout, _ = runMarkydown([]string{"-to", "html", "-full"}, "Hi")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "<html>\n<body>\n<p>Hi</p>\n</body>\n</html>\n")
+
+	status, stdout, _ = runMarkydown([]string{"-to", "text", "-width", "10"}, "# Hello\n\nWorld, hello")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "Hello\n=====\n\nWorld,\nhello\n")
+
+	status, stdout, _ = runMarkydown([]string{"-to", "md"}, "#  Hello\n\n\n*World*,\n   hello")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "# Hello\n\n*World*, hello\n")
+
+	status, stdout, _ = runMarkydown([]string{"-to", "commonmark"}, "+ snake_case")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "- snake\\_case\n")
+
+	status, stdout, _ = runMarkydown([]string{"-to", "json"}, "")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "{\"event\":\"StartDocument\"}\n{\"event\":\"EndDocument\"}\n")
+
+	status, stdout, _ = runMarkydown([]string{"-to", "latex"}, "# 100%")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "\\section{100\\%}\n")
+
+	status, stdout, _ = runMarkydown([]string{"-to", "pandoc"}, "")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "{\"pandoc-api-version\":[1,23,1],\"meta\":{},\"blocks\":[]}\n")
+
+	status, stdout, _ = runMarkydown([]string{"-max-heading-level", "1"}, "## Hi")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "<p>## Hi</p>\n")
+}
+
+// Tests bad command lines.
+func TestMarkydownUsage(t *testing.T) {
+	status, stdout, stderr := runMarkydown([]string{"-to", "pdf"}, "")
+	assert.Equal(t, status, exitError)
+	assert.Equal(t, stdout, "")
+	assert.Equal(t, stderr, "markydown: unknown output format \"pdf\"\n")
+
+	status, _, _ = runMarkydown([]string{"-no-such-flag"}, "")
+	assert.Equal(t, status, exitError)
+}
+
+// Tests converting files.
+func TestMarkydownFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "markydown")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	one := filepath.Join(dir, "one.md")
+	two := filepath.Join(dir, "two.md")
+	out := filepath.Join(dir, "out.html")
+	assert.Equal(t, ioutil.WriteFile(one, []byte("+ One"), 0644), nil)
+	assert.Equal(t, ioutil.WriteFile(two, []byte("+ Two\n"), 0644), nil)
+
+	status, stdout, stderr := runMarkydown([]string{"-o", out, one, two}, "")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "")
+	assert.Equal(t, stderr, "")
+
+	output, err := ioutil.ReadFile(out)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(output), "<ul>\n<li><p>One</p></li>\n<li><p>Two</p></li>\n</ul>\n")
+
+	status, _, stderr = runMarkydown([]string{filepath.Join(dir, "missing.md")}, "")
+	assert.Equal(t, status, exitError)
+	assert.Equal(t, strings.HasPrefix(stderr, "markydown: "), true)
+}
@@ -0,0 +1,95 @@
+// Command markylint checks Markydown documents for things that are probably
+// mistakes, like emphasis that is never closed or brackets that were meant to
+// be links.
+//
+// Usage:
+//
+//	markylint [flags] [file ...]
+//
+// Each problem found is printed to the standard output, in the usual
+// `file:line:col: message` format. If no files are given, the standard input is
+// checked.
+//
+// The exit status is 0 if no problems were found, 1 if some problem was found,
+// and 2 if the command line was bad or reading failed. This makes markylint
+// suitable for use in commit hooks and build scripts. (markydown follows the
+// same convention, exiting with 2 on errors.)
+//
+// The flags are:
+//
+//	-max-heading-level n
+//		The maximum heading level allowed (from 1 to 6; the default is 3).
+//		Headings deeper than this are reported, as they are parsed as regular
+//		text.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	markydown "github.com/lmbarros/sbxs_go_markydown"
+)
+
+// Exit statuses.
+const (
+	exitOK       = 0 // No problems found
+	exitProblems = 1 // Some problem found in the documents
+	exitError    = 2 // Bad command line, or reading failed
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs markylint with the given command-line arguments (excluding the
+// program name) and standard streams, and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("markylint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	maxHeadingLevel := flags.Int("max-heading-level", 3, "maximum heading level allowed")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: markylint [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	options := []markydown.Option{markydown.MaxHeadingLevel(*maxHeadingLevel)}
+	status := exitOK
+
+	check := func(name string, document []byte) {
+		for _, d := range markydown.ParseWithDiagnostics(string(document), nil, options...) {
+			fmt.Fprintf(stdout, "%s:%d:%d: %s\n", name, d.Span.Line, d.Span.Column, d.Message)
+			if status == exitOK {
+				status = exitProblems
+			}
+		}
+	}
+
+	if flags.NArg() == 0 {
+		document, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "markylint: %v\n", err)
+			return exitError
+		}
+		check("<stdin>", document)
+		return status
+	}
+
+	for _, name := range flags.Args() {
+		document, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "markylint: %v\n", err)
+			status = exitError
+			continue
+		}
+		check(name, document)
+	}
+
+	return status
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// runMarkylint runs markylint with some arguments and standard input, and
+// returns its exit status and outputs.
+func runMarkylint(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+// Tests checking documents with and without problems.
+func TestMarkylint(t *testing.T) {
+	status, stdout, stderr := runMarkylint(nil, "# Fine\n\nAll *fine* here.\n")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "")
+	assert.Equal(t, stderr, "")
+
+	status, stdout, stderr = runMarkylint(nil, "# Title #\n\nSome *text\n+ [and](link\n")
+	assert.Equal(t, status, exitProblems)
+	assert.Equal(t, stdout,
+		"<stdin>:1:9: trailing `#`s are not supported in headings; they are part of the heading text\n"+
+			"<stdin>:3:6: `*` is never closed; closing it at the end of the paragraph\n"+
+			"<stdin>:4:1: list items must be preceded by a blank line or another list item; parsing it as regular text\n"+
+			"<stdin>:4:3: link target is never closed with `)`; parsing it as regular text\n")
+	assert.Equal(t, stderr, "")
+}
+
+// Tests passing options to the parser.
+func TestMarkylintMaxHeadingLevel(t *testing.T) {
+	status, stdout, _ := runMarkylint(nil, "### Three\n\n#### Four")
+	assert.Equal(t, status, exitProblems)
+	assert.Equal(t, stdout,
+		"<stdin>:3:1: heading level 4 is not supported (the maximum is 3); parsing it as regular text\n")
+
+	status, stdout, _ = runMarkylint([]string{"-max-heading-level", "4"}, "#### Four")
+	assert.Equal(t, status, exitOK)
+	assert.Equal(t, stdout, "")
+
+	status, _, _ = runMarkylint([]string{"-no-such-flag"}, "")
+	assert.Equal(t, status, exitError)
+}
+
+// Tests checking files.
+func TestMarkylintFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "markylint")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	good := filepath.Join(dir, "good.md")
+	bad := filepath.Join(dir, "bad.md")
+	assert.Equal(t, ioutil.WriteFile(good, []byte("Good."), 0644), nil)
+	assert.Equal(t, ioutil.WriteFile(bad, []byte("\n[Bad]"), 0644), nil)
+
+	status, stdout, _ := runMarkylint([]string{good, bad}, "")
+	assert.Equal(t, status, exitProblems)
+	assert.Equal(t, stdout,
+		bad+":2:1: `[` and `]` not followed by a link target; parsing them as regular text\n")
+
+	status, stdout, stderr := runMarkylint([]string{filepath.Join(dir, "missing.md"), good}, "")
+	assert.Equal(t, status, exitError)
+	assert.Equal(t, stdout, "")
+	assert.Equal(t, strings.HasPrefix(stderr, "markylint: "), true)
+}
@@ -0,0 +1,279 @@
+package markydown
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// CodeProcessor is a Processor that wants to know about code spans.
+//
+// A code span is text between backticks, which is taken literally: emphasis
+// markers, links and escapes don't work within it. A code span can be
+// delimited by runs of more than one backtick, as long as it starts and ends
+// with runs of the same length, so that backticks can be part of the code. If
+// the code starts and ends with a space, one space is removed from each side,
+// which allows code starting or ending with a backtick. New lines within code
+// spans are taken as spaces. For example, this Markydown:
+//
+//	Call `Parse(*doc*)` or ``a ` b`` or `` `c` ``
+//
+// has code spans with the texts "Parse(*doc*)", "a ` b" and "`c`".
+//
+// If the Processor passed to the parser implements this interface, code spans
+// are reported with calls to Code. Otherwise, they are reported as regular
+// calls to Fragment.
+//
+// Code blocks are reported to every Processor as paragraphs of type
+// ParTypeCodeBlock, but only CodeProcessors are told about their info
+// strings. A code block starts with a line with at least three backticks
+// (the fence), optionally followed by an info string (usually the language
+// of the code), and ends with a line with at least as many backticks as the
+// opening fence (or at the end of the document). For example:
+//
+//	```go
+//	fmt.Println("Hi!")
+//	```
+//
+// The lines between the fences are taken verbatim, except that as much
+// indentation as the opening fence has is removed from each of them.
+type CodeProcessor interface {
+	Processor
+
+	// Code is called with the contents of a code span.
+	Code(text string)
+
+	// CodeBlockInfo is called right before StartParagraph for every code
+	// block, with its info string (which may be empty).
+	CodeBlockInfo(info string)
+}
+
+// minCodeFence is the minimum number of backticks in the fence that starts a
+// code block.
+const minCodeFence = 3
+
+// parseCodeBlock parses a fenced code block. Returns true if the parsing
+// succeeded or false otherwise (in which case no input is consumed).
+func (p *parser) parseCodeBlock() bool {
+	line, pos, _ := p.lookAheadLine(0)
+	fence, info := codeFence(line)
+	if fence == 0 {
+		return false
+	}
+
+	infoStart := p.offset() + fence + strings.Index(line[fence:], info)
+	p.input = p.input[len(line):]
+	pos -= len(line)
+
+	if p.coder != nil {
+		p.at(infoStart, infoStart+len(info))
+		p.coder.CodeBlockInfo(info)
+	}
+
+	p.startParagraph(ParTypeCodeBlock)
+
+	var lines []string
+	contentsStart := p.offsetOf(p.input[pos:])
+	contentsEnd := contentsStart
+	end := p.offset() // Where the last line of the block ends
+
+	for {
+		line, next, ok := p.lookAheadLine(pos)
+
+		if !ok {
+			p.diagnose(SeverityWarning, p.parStart, p.parStart+fence, CodeUnclosedCodeBlock,
+				"code block is never closed; it extends to the end of the document")
+			break
+		}
+
+		lineStart := p.offsetOf(p.input[pos:])
+		pos = next
+		end = lineStart + len(line)
+
+		if isClosingCodeFence(line, fence) {
+			break
+		}
+
+		lines = append(lines, removeIndentation(line, p.parIndent))
+		contentsEnd = end
+	}
+
+	if text := strings.Join(lines, "\n"); text != "" {
+		p.at(contentsStart, contentsEnd)
+		p.processor.Fragment(text)
+	}
+
+	p.input = p.input[pos:]
+	p.lastEnd = end // The closing fence is part of the paragraph
+	p.endParagraph(ParTypeCodeBlock)
+
+	return true
+}
+
+// codeFence checks if a given line (without its indentation) is the opening
+// fence of a code block. If so, returns the number of backticks in the fence
+// and the info string after them; otherwise, the returned fence is zero.
+func codeFence(line string) (fence int, info string) {
+	info = strings.TrimLeftFunc(line, isCodeDelimiter)
+	fence = len(line) - len(info)
+	info = strings.TrimFunc(info, isHorizontalSpace)
+
+	if fence < minCodeFence || strings.IndexFunc(info, isCodeDelimiter) >= 0 {
+		return 0, ""
+	}
+
+	return fence, info
+}
+
+// isClosingCodeFence checks if a given line closes a code block opened with a
+// fence with a given number of backticks.
+func isClosingCodeFence(line string, fence int) bool {
+	line = strings.TrimFunc(line, isHorizontalSpace)
+	return len(line) >= fence && strings.TrimLeftFunc(line, isCodeDelimiter) == ""
+}
+
+// removeIndentation removes up to a given number of columns of indentation
+// from a line.
+func removeIndentation(line string, columns int) string {
+	for i, r := range line {
+		if !isHorizontalSpace(r) {
+			return line[i:]
+		}
+		if indent, _ := indentationOf(line[:i+utf8.RuneLen(r)]); indent > columns {
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+// codeSpan checks if s starts with a code span, that is, a run of backticks
+// closed by another run of the same length before the end of the current
+// paragraph.
+//
+// Returns the contents of the code span and its length in the input
+// (including the backticks). If s doesn't start with a code span, length is
+// zero. In any case, fence is the length of the run of backticks s starts
+// with.
+func (p *parser) codeSpan(s string) (text string, length, fence int) {
+	fence = len(s) - len(strings.TrimLeftFunc(s, isCodeDelimiter))
+	rest := s[fence:]
+
+	for i := 0; i < len(rest); {
+		r, w := utf8.DecodeRuneInString(rest[i:])
+
+		switch {
+		case p.isParagraphEnd(rest[i:]):
+			return "", 0, fence
+
+		case isCodeDelimiter(r):
+			run := len(rest[i:]) - len(strings.TrimLeftFunc(rest[i:], isCodeDelimiter))
+			if run == fence {
+				return codeSpanText(rest[:i]), fence + i + run, fence
+			}
+			i += run
+
+		default:
+			i += w
+		}
+	}
+
+	return "", 0, fence
+}
+
+// codeSpanText returns the text of a code span whose raw contents (between the
+// backticks) are raw. New lines (and the indentation after them) become
+// single spaces, and a space is removed from each side if there are spaces on
+// both sides.
+func codeSpanText(raw string) string {
+	var b strings.Builder
+
+	for len(raw) > 0 {
+		r, w := utf8.DecodeRuneInString(raw)
+		raw = raw[w:]
+
+		if !isNewLine(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		raw = strings.TrimLeftFunc(skipNewLine(raw, r), isHorizontalSpace)
+		b.WriteRune(' ')
+	}
+
+	text := b.String()
+	if len(text) >= 2 && text[0] == ' ' && text[len(text)-1] == ' ' && strings.Trim(text, " ") != "" {
+		text = text[1 : len(text)-1]
+	}
+
+	return text
+}
+
+// formatCodeSpan returns a code span with a given text, delimited by as few
+// backticks as possible. The same syntax works for Markydown and CommonMark.
+func formatCodeSpan(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	runs := make(map[int]bool)
+	for s := text; s != ""; {
+		trimmed := strings.TrimLeftFunc(s, isCodeDelimiter)
+		if run := len(s) - len(trimmed); run > 0 {
+			runs[run] = true
+			s = trimmed
+			continue
+		}
+		_, w := utf8.DecodeRuneInString(s)
+		s = s[w:]
+	}
+
+	fence := 1
+	for runs[fence] {
+		fence++
+	}
+
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") ||
+		(strings.HasPrefix(text, " ") && strings.HasSuffix(text, " ") && strings.Trim(text, " ") != "") {
+		text = " " + text + " "
+	}
+
+	delimiter := strings.Repeat("`", fence)
+	return delimiter + text + delimiter
+}
+
+// codeLanguage returns the language of a code block with a given info string,
+// which is its first word (if any).
+func codeLanguage(info string) string {
+	if words := strings.Fields(info); len(words) > 0 {
+		return words[0]
+	}
+	return ""
+}
+
+// formatCodeBlock returns a fenced code block with a given info string and
+// text, delimited by enough backticks. The same syntax works for Markydown
+// and CommonMark.
+func formatCodeBlock(info, text string) string {
+	fence := minCodeFence
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimFunc(line, isHorizontalSpace)
+		if strings.TrimLeftFunc(line, isCodeDelimiter) == "" && len(line) >= fence {
+			fence = len(line) + 1
+		}
+	}
+
+	delimiter := strings.Repeat("`", fence)
+	if text != "" {
+		text += "\n"
+	}
+
+	return delimiter + info + "\n" + text + delimiter + "\n"
+}
+
+// code tells the processor about a code span with a given text.
+func (p *parser) code(text string) {
+	if p.coder != nil {
+		p.coder.Code(text)
+	} else {
+		p.processor.Fragment(text)
+	}
+}
@@ -0,0 +1,529 @@
+package markydown
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CommonMarkRenderer is a Processor that renders a Markydown document as
+// CommonMark Markdown with the same meaning, writing the results to an
+// io.Writer. This is intended for feeding Markydown documents to tools that
+// understand only standard Markdown.
+//
+// Characters that have a special meaning in CommonMark (like `_`, which is
+// just text in Markydown) are escaped, non-breaking spaces are written as
+// `&nbsp;`, and parentheses, spaces and `&`s in link targets (and image
+// sources) are escaped. Emphasis and strong emphasis are written with `*` and
+// `**` where CommonMark would recognize them as such (which is not always the
+// case, for example, with emphasized text starting with a space), and as `<em>`
+// and `<strong>` HTML elements elsewhere.
+//
+// Code spans and code blocks are written just like in Markydown.
+//
+// Paragraphs are not wrapped, and hard line breaks are written as a backslash
+// at the end of the line (or as `<br>` in headings). Lists are rendered just
+// like in CommonMark, using `-` as bullet, and so are block quotes.
+type CommonMarkRenderer struct {
+	w          io.Writer         // Where the output goes to
+	err        error             // The first error found while writing, if any
+	started    bool              // Have we written any paragraph yet?
+	pieces     []commonMarkPiece // Pieces of the current paragraph
+	textStyle  TextStyle         // The current text style
+	openStyles []int             // Indices into pieces of the opening markers of the open styles, in the order they were opened
+	links      []string          // Stack of targets of the links we are in
+	lists      []textList        // Stack of lists we are in
+	marker     string            // Marker (like a bullet) of the list item whose paragraph is next
+	tightItem  bool              // Is the next paragraph an item of a tight list that needs no blank line before it?
+	blankItem  bool              // Does the next paragraph need a blank line before it even if tightItem is set?
+	codeInfo   string            // Info string of the next code block
+	codeBlock  bool              // Are we in a code block?
+	code       string            // Contents of the current code block
+	quotes     textQuotes        // The block quotes we are in
+}
+
+// commonMarkPiece is a piece of a paragraph rendered by a CommonMarkRenderer:
+// either some text (already escaped) or an emphasis marker.
+type commonMarkPiece struct {
+	text      string    // The text; empty for emphasis markers and line breaks
+	lineBreak bool      // Is this a hard line break?
+	style     TextStyle // For emphasis markers, the (single, not combined) style it opens or closes
+	opening   bool      // For emphasis markers, is it opening the style?
+	partner   int       // For emphasis markers, index of the marker closing or opening the same style
+	html      bool      // For emphasis markers, shall it be written as an HTML tag?
+}
+
+// NewCommonMarkRenderer creates a new CommonMarkRenderer that writes its
+// output to w.
+func NewCommonMarkRenderer(w io.Writer) *CommonMarkRenderer {
+	return &CommonMarkRenderer{
+		w: w,
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *CommonMarkRenderer) Err() error {
+	return r.err
+}
+
+// StartDocument implements the Processor interface.
+func (r *CommonMarkRenderer) StartDocument() {
+	r.started = false
+	r.textStyle = TextStyleRegular
+	r.openStyles = nil
+	r.links = nil
+	r.lists = nil
+	r.marker = ""
+	r.tightItem = false
+	r.blankItem = false
+	r.codeInfo = ""
+	r.quotes = textQuotes{}
+}
+
+// EndDocument implements the Processor interface.
+func (r *CommonMarkRenderer) EndDocument() {
+}
+
+// StartParagraph implements the Processor interface.
+func (r *CommonMarkRenderer) StartParagraph(parType ParType) {
+	r.pieces = nil
+	r.openStyles = nil
+	r.codeBlock = parType == ParTypeCodeBlock
+	r.code = ""
+}
+
+// EndParagraph implements the Processor interface.
+func (r *CommonMarkRenderer) EndParagraph(parType ParType) {
+	r.closeStylesFor(TextStyleRegular)
+	r.chooseMarkers()
+
+	if r.started && (!r.tightItem || r.blankItem) {
+		r.write(r.quotes.separator())
+	}
+	r.started = true
+	r.tightItem = false
+	r.blankItem = false
+	r.quotes.paragraph()
+
+	if r.codeBlock {
+		r.write(r.quotes.quote(formatCodeBlock(r.codeInfo, r.code)))
+		r.codeInfo = ""
+		r.codeBlock = false
+		return
+	}
+
+	indent := ""
+	prefix := ""
+
+	switch {
+	case parType.HeadingLevel() > 0:
+		prefix = strings.Repeat("#", parType.HeadingLevel()) + " "
+
+	case r.marker != "":
+		l := r.lists[len(r.lists)-1]
+		indent = strings.Repeat(" ", l.textIndent)
+		prefix = strings.Repeat(" ", l.indent) + r.marker
+	}
+
+	var b strings.Builder
+	for _, piece := range r.pieces {
+		switch {
+		case piece.lineBreak && parType.HeadingLevel() > 0:
+			b.WriteString("<br>")
+		case piece.lineBreak:
+			b.WriteString("\\\n")
+		case piece.text != "":
+			b.WriteString(piece.text)
+		default:
+			b.WriteString(commonMarkMarker(piece))
+		}
+	}
+
+	text := b.String()
+
+	// A closing sequence of `#`s is not part of the heading text
+	if parType.HeadingLevel() > 0 && strings.HasSuffix(text, "#") {
+		hashes := len(text) - len(strings.TrimRight(text, "#"))
+		text = text[:len(text)-hashes] + "\\" + text[len(text)-hashes:]
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 || parType.HeadingLevel() == 0 {
+			line = escapeCommonMarkLineStart(line)
+		}
+
+		if i == 0 {
+			line = prefix + line
+		} else {
+			line = indent + line
+		}
+
+		r.write(r.quotes.quote(line + "\n"))
+	}
+
+	r.marker = ""
+	r.pieces = nil
+}
+
+// Fragment implements the Processor interface.
+func (r *CommonMarkRenderer) Fragment(text string) {
+	if r.codeBlock {
+		r.code += text
+		return
+	}
+
+	r.openStylesFor(r.textStyle)
+	r.pieces = append(r.pieces, commonMarkPiece{text: escapeCommonMark(text)})
+}
+
+// SpecialToken implements the Processor interface.
+func (r *CommonMarkRenderer) SpecialToken(token SpecialToken) {
+	switch token {
+	case SpecialTokenSpace:
+		r.openStylesFor(r.textStyle)
+		r.pieces = append(r.pieces, commonMarkPiece{text: " "})
+	case SpecialTokenNonBreakingSpace:
+		r.openStylesFor(r.textStyle)
+		r.pieces = append(r.pieces, commonMarkPiece{text: "&nbsp;"})
+	case SpecialTokenLineBreak:
+		r.pieces = append(r.pieces, commonMarkPiece{lineBreak: true})
+	}
+}
+
+// ChangeTextStyle implements the Processor interface.
+//
+// Just like in the HTMLRenderer, the emphasis markers for the new styles are
+// added only when some content is added.
+func (r *CommonMarkRenderer) ChangeTextStyle(style TextStyle) {
+	r.closeStylesFor(style)
+	r.textStyle = style
+}
+
+// StartLink implements the Processor interface.
+//
+// Styles are closed before and reopened within the link text, so that they
+// are properly nested (CommonMark would not recognize emphasis markers that
+// are not).
+func (r *CommonMarkRenderer) StartLink(target string) {
+	r.closeStylesFor(TextStyleRegular)
+	r.links = append(r.links, target)
+
+	// `![` would start an image
+	if n := len(r.pieces); n > 0 && strings.HasSuffix(r.pieces[n-1].text, "!") &&
+		!strings.HasSuffix(r.pieces[n-1].text, "\\!") {
+		r.pieces[n-1].text = strings.TrimSuffix(r.pieces[n-1].text, "!") + "\\!"
+	}
+
+	r.pieces = append(r.pieces, commonMarkPiece{text: "["})
+}
+
+// EndLink implements the Processor interface.
+func (r *CommonMarkRenderer) EndLink() {
+	r.closeStylesFor(TextStyleRegular)
+
+	if len(r.links) == 0 {
+		return
+	}
+
+	target := r.links[len(r.links)-1]
+	r.links = r.links[:len(r.links)-1]
+
+	r.pieces = append(r.pieces, commonMarkPiece{text: "](" + escapeCommonMarkTarget(target) + ")"})
+}
+
+// Code implements the CodeProcessor interface.
+func (r *CommonMarkRenderer) Code(text string) {
+	r.openStylesFor(r.textStyle)
+	r.pieces = append(r.pieces, commonMarkPiece{text: formatCodeSpan(text)})
+}
+
+// CodeBlockInfo implements the CodeProcessor interface.
+func (r *CommonMarkRenderer) CodeBlockInfo(info string) {
+	r.codeInfo = info
+}
+
+// StartList implements the ListProcessor interface.
+func (r *CommonMarkRenderer) StartList(list ListInfo) {
+	indent := 0
+	if len(r.lists) > 0 {
+		indent = r.lists[len(r.lists)-1].textIndent
+	}
+
+	r.lists = append(r.lists, textList{
+		info:   list,
+		indent: indent,
+	})
+}
+
+// EndList implements the ListProcessor interface.
+func (r *CommonMarkRenderer) EndList(list ListInfo) {
+	if len(r.lists) > 0 {
+		r.lists = r.lists[:len(r.lists)-1]
+	}
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *CommonMarkRenderer) StartListItem(list ListInfo) {
+	if len(r.lists) == 0 {
+		return
+	}
+
+	l := &r.lists[len(r.lists)-1]
+
+	if l.info.Kind == ListKindOrdered {
+		r.marker = strconv.Itoa(l.info.Start+l.items) + ". "
+	} else {
+		r.marker = "- "
+	}
+
+	r.tightItem = l.info.Tight && (l.items > 0 || l.info.Depth > 1)
+
+	// In CommonMark, an ordered list can interrupt a paragraph only if it
+	// starts at 1.
+	r.blankItem = l.items == 0 && l.info.Kind == ListKindOrdered && l.info.Start != 1
+
+	l.items++
+	l.textIndent = l.indent + utf8.RuneCountInString(r.marker)
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *CommonMarkRenderer) EndListItem(list ListInfo) {
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *CommonMarkRenderer) StartQuote() {
+	r.quotes.start()
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *CommonMarkRenderer) EndQuote() {
+	if r.quotes.empty {
+		if r.started {
+			r.write(r.quotes.separator())
+		}
+		r.started = true
+		r.write(r.quotes.quote("\n"))
+		r.quotes.paragraph()
+	}
+
+	r.quotes.end()
+}
+
+// Image implements the ImageProcessor interface.
+func (r *CommonMarkRenderer) Image(source, alt string) {
+	r.openStylesFor(r.textStyle)
+	r.pieces = append(r.pieces, commonMarkPiece{
+		text: "![" + escapeCommonMark(alt) + "](" + escapeCommonMarkTarget(source) + ")",
+	})
+}
+
+// closeStylesFor adds closing markers for the open styles that are not part of
+// style. Styles opened after them are closed too (to keep them properly
+// nested), and will be reopened by the next openStylesFor.
+func (r *CommonMarkRenderer) closeStylesFor(style TextStyle) {
+	keep := 0
+	for keep < len(r.openStyles) && style.Has(r.pieces[r.openStyles[keep]].style) {
+		keep++
+	}
+
+	for i := len(r.openStyles) - 1; i >= keep; i-- {
+		opening := r.openStyles[i]
+		r.pieces[opening].partner = len(r.pieces)
+		r.pieces = append(r.pieces, commonMarkPiece{style: r.pieces[opening].style, partner: opening})
+	}
+
+	r.openStyles = r.openStyles[:keep]
+}
+
+// openStylesFor adds opening markers for the styles in style that are not open
+// yet.
+func (r *CommonMarkRenderer) openStylesFor(style TextStyle) {
+	for _, s := range textStyles {
+		if style.Has(s) && !r.isStyleOpen(s) {
+			r.openStyles = append(r.openStyles, len(r.pieces))
+			r.pieces = append(r.pieces, commonMarkPiece{style: s, opening: true})
+		}
+	}
+}
+
+// isStyleOpen checks if a given style is open.
+func (r *CommonMarkRenderer) isStyleOpen(style TextStyle) bool {
+	for _, i := range r.openStyles {
+		if r.pieces[i].style == style {
+			return true
+		}
+	}
+	return false
+}
+
+// chooseMarkers decides which emphasis markers of the current paragraph must
+// be written as HTML tags, because CommonMark would not recognize them as
+// emphasis if written with `*`s.
+//
+// Each run of consecutive `*`s must be either only opening or only closing
+// emphasis, as determined by the CommonMark rules for "left-flanking" and
+// "right-flanking" delimiter runs. When a run doesn't work, its styles are
+// written as HTML tags, which changes the runs around, so we repeat until all
+// runs work.
+func (r *CommonMarkRenderer) chooseMarkers() {
+	for changed := true; changed; {
+		changed = false
+
+		for start := 0; start < len(r.pieces); start++ {
+			if !r.isStarMarker(start) {
+				continue
+			}
+
+			end := start
+			opening, closing := false, false
+			for ; end < len(r.pieces) && r.isStarMarker(end); end++ {
+				opening = opening || r.pieces[end].opening
+				closing = closing || !r.pieces[end].opening
+			}
+
+			prev, next := r.pieceRune(start-1, false), r.pieceRune(end, true)
+			left := isLeftFlanking(prev, next)
+			right := isLeftFlanking(next, prev)
+
+			for i := start; i < end; i++ {
+				piece := &r.pieces[i]
+				works := closing && right && !left
+				if piece.opening {
+					works = !closing && left && !right
+				}
+
+				if !works {
+					piece.html = true
+					r.pieces[piece.partner].html = true
+					changed = true
+				}
+			}
+
+			start = end
+		}
+	}
+}
+
+// isStarMarker checks if the piece at a given index is an emphasis marker to
+// be written with `*`s.
+func (r *CommonMarkRenderer) isStarMarker(i int) bool {
+	piece := r.pieces[i]
+	return piece.text == "" && !piece.lineBreak && !piece.html
+}
+
+// pieceRune returns the first (if first is true) or last rune of the piece
+// with a given index, as it will be written. Out of bounds indices represent
+// the start or the end of the paragraph, which count as a space.
+func (r *CommonMarkRenderer) pieceRune(i int, first bool) rune {
+	var s string
+
+	switch {
+	case i < 0 || i >= len(r.pieces):
+		return ' '
+	case r.pieces[i].lineBreak && first:
+		return '\\'
+	case r.pieces[i].lineBreak:
+		return '\n'
+	case r.pieces[i].text != "":
+		s = r.pieces[i].text
+	default:
+		s = commonMarkMarker(r.pieces[i])
+	}
+
+	if first {
+		c, _ := utf8.DecodeRuneInString(s)
+		return c
+	}
+	c, _ := utf8.DecodeLastRuneInString(s)
+	return c
+}
+
+// write writes s to the output, unless a previous write failed.
+func (r *CommonMarkRenderer) write(s string) {
+	if r.err != nil || len(s) == 0 {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
+
+// commonMarkMarker returns the text used to write an emphasis marker.
+func commonMarkMarker(piece commonMarkPiece) string {
+	switch {
+	case piece.html && piece.opening:
+		return htmlStyleOpeningTag(piece.style)
+	case piece.html:
+		return htmlStyleClosingTag(piece.style)
+	case piece.style == TextStyleStrong:
+		return "**"
+	default:
+		return "*"
+	}
+}
+
+// isLeftFlanking checks if a delimiter run between the runes prev and next is
+// left-flanking, as defined by CommonMark. (Swap them to check if it is
+// right-flanking.)
+func isLeftFlanking(prev, next rune) bool {
+	return !unicode.IsSpace(next) &&
+		(!isCommonMarkPunctuation(next) || unicode.IsSpace(prev) || isCommonMarkPunctuation(prev))
+}
+
+// isCommonMarkPunctuation checks if a given rune is a punctuation character,
+// as defined by CommonMark.
+func isCommonMarkPunctuation(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// escapeCommonMarkLineStart escapes the start of a line of text, if needed to
+// prevent CommonMark from taking it as the start of some block, like a heading
+// or a list item.
+func escapeCommonMarkLineStart(line string) string {
+	if line == "" {
+		return line
+	}
+
+	if strings.ContainsRune("#+-=>~", rune(line[0])) {
+		return "\\" + line
+	}
+
+	digits := len(line) - len(strings.TrimLeftFunc(line, isDigit))
+	if digits > 0 && digits < len(line) && (line[digits] == '.' || line[digits] == ')') {
+		return line[:digits] + "\\" + line[digits:]
+	}
+
+	return line
+}
+
+// escapeCommonMark escapes a text, so that CommonMark doesn't take any of its
+// characters as markup.
+func escapeCommonMark(text string) string {
+	var b strings.Builder
+	for _, c := range text {
+		if strings.ContainsRune("\\`*_[]<&", c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// escapeCommonMarkTarget escapes a link target (or image source) to be written
+// between parentheses in CommonMark.
+func escapeCommonMarkTarget(target string) string {
+	var b strings.Builder
+	for _, c := range target {
+		switch {
+		case c <= ' ' || c == 0x7f:
+			b.WriteString("%" + strings.ToUpper(strconv.FormatInt(int64(c)|0x100, 16)[1:]))
+		case strings.ContainsRune("\\()<>&", c):
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
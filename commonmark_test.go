@@ -0,0 +1,77 @@
+package markydown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// renderCommonMark renders a Markydown document as CommonMark.
+func renderCommonMark(input string) string {
+	var buf bytes.Buffer
+	Parse(input, NewCommonMarkRenderer(&buf))
+	return buf.String()
+}
+
+// Tests rendering CommonMark.
+func TestCommonMarkRenderer(t *testing.T) {
+	testData := map[string]string{
+		"": "",
+
+		// Escapes
+		"Snake_case, 2 \\* 3, \\`tick\\` and <b>&amp;": "Snake\\_case, 2 \\* 3, \\`tick\\` and \\<b>\\&amp;\n",
+		"Escaped \\*stars\\* and \\\\":                 "Escaped \\*stars\\* and \\\\\n",
+		"Non-breaking 100\\ kg":                        "Non-breaking 100&nbsp;kg\n",
+		"1\\. Not a list\n\n\\+ Nor this":              "1\\. Not a list\n\n\\+ Nor this\n",
+		"Text\\\n# not a heading\\\n---":               "Text\\\n\\# not a heading\\\n\\---\n",
+		"# C#":                                         "# C\\#\n",
+		"# Two\\\nlines":                               "# Two<br>lines\n",
+
+		// Emphasis
+		"*a* **b** ***c***":        "*a* **b** ***c***\n",
+		"**a *b** c*":              "**a *b***<em> c</em>\n",
+		"a* b*, Unbe*lie*vable":    "a<em> b</em>, Unbe<em>lie</em>vable\n",
+		"*\"Quoted\"*, (**this**)": "<em>\"Quoted\"</em>, (**this**)\n",
+		"*Unclosed":                "*Unclosed*\n",
+
+		// Code
+		"`a_b` and ``c`d`` and `` `e` ``": "`a_b` and ``c`d`` and `` `e` ``\n",
+		"*`a`* **`<b>`**":                 "*`a`* **`<b>`**\n",
+
+		// Code blocks
+		"+ Item\n\n```go\n_a_\n```\n  ````\n```": "- Item\n\n```go\n_a_\n```\n\n````\n```\n````\n",
+
+		// Links
+		"[Link](x/a_\\(b\\) c)":  "[Link](x/a_\\(b\\)%20c)\n",
+		"*Emphasized [link](x)*": "<em>Emphasized </em>[*link*](x)\n",
+		"Wow\\![link](x)":        "Wow\\![link](x)\n",
+		"[a&amp;b](a&amp;b)":     "[a\\&amp;b](a\\&amp;b)\n",
+
+		// Images
+		"![*A* c_a\\[t](x \\(1\\).png)": "![A c\\_a\\[t](x%20\\(1\\).png)\n",
+		"![&lt;](&lt;.png)":             "![\\&lt;](\\&lt;.png)\n",
+
+		// Lists
+		"+ One\n\n+ Two":                   "- One\n\n- Two\n",
+		"+ One\n    + Nested\n+ Two":       "- One\n  - Nested\n- Two\n",
+		"10. Ten\n    + Nested\\\n  break": "10. Ten\n    - Nested\\\n      break\n",
+		"+ One\n    2. Two\n+ Three":       "- One\n\n  2. Two\n- Three\n",
+		"+ \\+ Plus\n+ \\# Hash":           "- \\+ Plus\n- \\# Hash\n",
+
+		// Quotes
+		"> # Quote\nlazy\n>\n> > + a_b\n\n> Two\n\n>": "> # Quote lazy\n>\n> > - a\\_b\n\n> Two\n\n>\n",
+		"\\> Not quoted": "\\> Not quoted\n",
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, renderCommonMark(input), expected)
+	}
+}
+
+// Tests if write errors are reported.
+func TestCommonMarkRendererError(t *testing.T) {
+	r := NewCommonMarkRenderer(failingWriter{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
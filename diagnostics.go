@@ -0,0 +1,154 @@
+package markydown
+
+import "sort"
+
+// Severity tells how serious a Diagnostic is.
+type Severity int
+
+const (
+	// SeverityWarning is used for constructs that are valid Markydown, but are
+	// probably not what the author meant.
+	SeverityWarning Severity = iota
+
+	// SeverityError is used for constructs that are malformed, and that the
+	// parser had to guess how to handle.
+	SeverityError
+)
+
+// String returns a lowercase name for the severity, like "warning".
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// DiagnosticCode identifies the kind of problem a Diagnostic is about.
+type DiagnosticCode string
+
+const (
+	// CodeUnbalancedEmphasis is used when an emphasis marker (`*` or `**`)
+	// is left open at the end of a paragraph.
+	CodeUnbalancedEmphasis DiagnosticCode = "unbalanced-emphasis"
+
+	// CodeUnclosedLink is used when something looks like a link, but its
+	// target is not closed with a `)` before the end of the paragraph. It is
+	// parsed as regular text.
+	CodeUnclosedLink DiagnosticCode = "unclosed-link"
+
+	// CodeNotALink is used for text between `[` and `]` that is not followed
+	// by a link target, like `[this]`. This is fine if the brackets are meant
+	// to be there, but may be a link missing its target.
+	CodeNotALink DiagnosticCode = "not-a-link"
+
+	// CodeEmptyLinkTarget is used for links with an empty target, like
+	// `[text]()`. They are parsed as regular text.
+	CodeEmptyLinkTarget DiagnosticCode = "empty-link-target"
+
+	// CodeUnclosedCodeSpan is used when a backtick (or a run of them) is not
+	// closed by a run of the same length before the end of the paragraph. It
+	// is parsed as regular text.
+	CodeUnclosedCodeSpan DiagnosticCode = "unclosed-code-span"
+
+	// CodeUnclosedCodeBlock is used when a code block is not closed with a
+	// fence. It extends to the end of the document.
+	CodeUnclosedCodeBlock DiagnosticCode = "unclosed-code-block"
+
+	// CodeHeadingTrailingHashes is used for headings ending with hashes, like
+	// `## Heading ##`. Markydown doesn't support these, so the hashes are
+	// part of the heading text.
+	CodeHeadingTrailingHashes DiagnosticCode = "heading-trailing-hashes"
+
+	// CodeHeadingTooDeep is used for something that looks like a heading, but
+	// has more hashes than the maximum heading level allowed (see
+	// MaxHeadingLevel). It is parsed as a regular text paragraph.
+	CodeHeadingTooDeep DiagnosticCode = "heading-too-deep"
+
+	// CodeListItemInText is used for lines within a paragraph that look like
+	// list items. List items must follow a blank line or another list item,
+	// so these are parsed as regular text.
+	CodeListItemInText DiagnosticCode = "list-item-in-text"
+)
+
+// Diagnostic describes a potential problem found in a Markydown document.
+//
+// Markydown documents are never invalid: the parser always finds some way to
+// interpret its input. Diagnostics tell where the parser had to do something
+// the author may not have expected.
+type Diagnostic struct {
+	Severity Severity       // How serious the problem is
+	Span     Span           // Where the problem is in the input
+	Code     DiagnosticCode // What kind of problem this is
+	Message  string         // A human-readable description of the problem
+}
+
+// ParseWithDiagnostics parses a Markydown document passed as a string, just
+// like Parse, and returns the Diagnostics found in it, sorted by their position
+// in the input. If processor is nil, the document is only checked for
+// problems.
+//
+// Any ReportDiagnostics Option passed is overridden.
+func ParseWithDiagnostics(document string, processor Processor, options ...Option) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	if processor == nil {
+		processor = &noopProcessor{}
+	}
+
+	options = append(options[:len(options):len(options)], ReportDiagnostics(func(d Diagnostic) {
+		diagnostics = append(diagnostics, d)
+	}))
+
+	Parse(document, processor, options...)
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Span.StartOffset < diagnostics[j].Span.StartOffset
+	})
+
+	return diagnostics
+}
+
+// ReportDiagnostics returns an Option that makes the parser call handler for
+// each Diagnostic it finds, in the order they are found.
+func ReportDiagnostics(handler func(Diagnostic)) Option {
+	return func(o *options) {
+		o.diagnosticHandler = handler
+	}
+}
+
+// diagnose reports a Diagnostic about the input between offsets start and end
+// to the handler set with ReportDiagnostics, if any.
+func (p *parser) diagnose(severity Severity, start, end int, code DiagnosticCode, message string) {
+	if p.opts.diagnosticHandler == nil {
+		return
+	}
+
+	if p.quote != nil {
+		p.quote.outer.diagnose(severity, p.quote.outerOffset(start), p.quote.outerOffset(end), code, message)
+		return
+	}
+
+	p.opts.diagnosticHandler(Diagnostic{
+		Severity: severity,
+		Span:     p.span(start, end),
+		Code:     code,
+		Message:  message,
+	})
+}
+
+// noopProcessor is a Processor that doesn't do anything.
+type noopProcessor struct{}
+
+func (p *noopProcessor) StartDocument()                  {}
+func (p *noopProcessor) EndDocument()                    {}
+func (p *noopProcessor) StartParagraph(parType ParType)  {}
+func (p *noopProcessor) EndParagraph(parType ParType)    {}
+func (p *noopProcessor) Fragment(text string)            {}
+func (p *noopProcessor) SpecialToken(token SpecialToken) {}
+func (p *noopProcessor) ChangeTextStyle(style TextStyle) {}
+func (p *noopProcessor) StartLink(target string)         {}
+func (p *noopProcessor) EndLink()                        {}
@@ -0,0 +1,113 @@
+package markydown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// diagnosticToString converts a Diagnostic to a string with its code and
+// position, like "unbalanced-emphasis@1:3[2,4]".
+func diagnosticToString(d Diagnostic) string {
+	return string(d.Code) + "@" + spanToString(d.Span)
+}
+
+// Tests reporting unbalanced emphasis markers.
+func TestDiagnoseUnbalancedEmphasis(t *testing.T) {
+	testData := map[string][]string{
+		"*Fine* and **fine**": nil,
+		"*Not fine":           {"unbalanced-emphasis@1:1[0,1]"},
+		"a **b *c\n\nd *e":    {"unbalanced-emphasis@1:3[2,4]", "unbalanced-emphasis@1:7[6,7]", "unbalanced-emphasis@3:3[12,13]"},
+		"a *b **c* d":         {"unbalanced-emphasis@1:6[5,7]"},
+	}
+
+	for input, expected := range testData {
+		var actual []string
+		report := func(d Diagnostic) {
+			assert.Equal(t, d.Severity, SeverityWarning)
+			actual = append(actual, diagnosticToString(d))
+		}
+
+		Parse(input, &noopProcessor{}, ReportDiagnostics(report))
+		assert.Equal(t, actual, expected)
+
+		actual = nil
+		err := ParseReader(strings.NewReader(input), &noopProcessor{}, ReportDiagnostics(report))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actual, expected)
+	}
+}
+
+// Tests the diagnostics returned by ParseWithDiagnostics.
+func TestParseWithDiagnostics(t *testing.T) {
+	testData := map[string][]string{
+		"# Fine\n\nAll [fine](here), *really*.": nil,
+
+		// Links
+		"[Not](closed\n\n[ok](ok)":   {"unclosed-link@1:1[0,12]"},
+		"[a [b](c\n\nd":              {"unclosed-link@1:1[0,8]"},
+		"+ [a](b\n+ c)":              {"unclosed-link@1:3[2,7]"},
+		"[Empty]() *target":          {"empty-link-target@1:1[0,9]", "unbalanced-emphasis@1:11[10,11]"},
+		"[Not a link] (nor this) []": {"not-a-link@1:1[0,12]", "not-a-link@1:25[24,26]"},
+		"[a [b] c":                   {"not-a-link@1:1[0,6]"},
+		"![Not an image] x":          {"not-a-link@1:2[1,15]"},
+		"\\[Escaped\\]":              nil,
+
+		// Code spans
+		"`Not closed\n\n`ok`": {"unclosed-code-span@1:1[0,1]"},
+		"``a` b":              {"unclosed-code-span@1:1[0,2]", "unclosed-code-span@1:4[3,4]"},
+		"[`a](b)`":            nil,
+		"Text\n\n```go\n":     {"unclosed-code-block@3:1[6,9]"},
+
+		// Quotes
+		"> > *a\n> > [b](c": {"unbalanced-emphasis@1:5[4,5]", "unclosed-link@2:5[11,16]"},
+
+		// Headings
+		"# Title #":           {"heading-trailing-hashes@1:9[8,9]"},
+		"## Two\n\tlines ## ": {"heading-trailing-hashes@2:8[14,16]"},
+		"### C#":              nil,
+		"Text #":              nil,
+		"####### Seven":       {"heading-too-deep@1:1[0,7]"},
+		"#######":             nil,
+
+		// Lists
+		"Text\n+ Item\n  1. Item":      {"list-item-in-text@2:1[5,6]", "list-item-in-text@3:3[14,16]"},
+		"+ Item\n+ Item\n\n1. Item":    nil,
+		"Text\\\n+ Item\n\n2019. Year": nil,
+
+		// Sorted by position, even if found in a different order
+		"*a [b](c": {"unbalanced-emphasis@1:1[0,1]", "unclosed-link@1:4[3,8]"},
+	}
+
+	for input, expected := range testData {
+		var actual []string
+		for _, d := range ParseWithDiagnostics(input, nil) {
+			actual = append(actual, diagnosticToString(d))
+		}
+		assert.Equal(t, actual, expected)
+	}
+}
+
+// Tests if ParseWithDiagnostics passes the parsing events to the processor.
+func TestParseWithDiagnosticsProcessor(t *testing.T) {
+	p := &testProcessor{}
+	diagnostics := ParseWithDiagnostics("# Hi #", p, MaxHeadingLevel(1))
+
+	assert.Equal(t, p.res, []string{"SD", "SP-H1", "F-Hi", "ST-SP", "F-#", "EP-H1", "ED"})
+	assert.Equal(t, diagnostics, []Diagnostic{{
+		Severity: SeverityWarning,
+		Span:     Span{StartOffset: 5, EndOffset: 6, Line: 1, Column: 6},
+		Code:     CodeHeadingTrailingHashes,
+		Message:  "trailing `#`s are not supported in headings; they are part of the heading text",
+	}})
+}
+
+// Tests diagnostics that depend on the options in effect.
+func TestParseWithDiagnosticsOptions(t *testing.T) {
+	diagnostics := ParseWithDiagnostics("### Three\n\n#### Four", nil, MaxHeadingLevel(3))
+	assert.Equal(t, len(diagnostics), 1)
+	assert.Equal(t, diagnosticToString(diagnostics[0]), "heading-too-deep@3:1[11,15]")
+	assert.Equal(t, diagnostics[0].Message,
+		"heading level 4 is not supported (the maximum is 3); parsing it as regular text")
+}
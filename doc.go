@@ -5,4 +5,11 @@
 // converter. The provided parser will simply call some methods of a
 // user-supplied `Processor` object as it detects, for example, that a new
 // paragraph started, the formatting changed or some text is to be "emitted".
+//
+// That said, an HTMLRenderer, a TextRenderer, an ANSIRenderer, a
+// CommonMarkRenderer and a LaTeXRenderer are provided, so that converting
+// Markydown to HTML, plain text, text for terminals, standard Markdown or LaTeX
+// doesn't require writing a Processor. JSONRenderer and PandocRenderer help
+// processing Markydown documents elsewhere, and Format (which uses a
+// MarkydownRenderer) formats Markydown documents in a canonical way.
 package markydown
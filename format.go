@@ -0,0 +1,456 @@
+package markydown
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format parses a Markydown document and returns it formatted in a canonical
+// way, as generated by a MarkydownRenderer with its default settings. Options
+// are used when parsing the document; the formatted output doesn't depend on
+// them.
+//
+// Formatting doesn't change the meaning of a document: parsing the formatted
+// document generates exactly the same calls to a Processor (or ListProcessor,
+// CodeProcessor, QuoteProcessor or ImageProcessor) as parsing the original one.
+func Format(document string, options ...Option) string {
+	var b bytes.Buffer
+	Parse(document, NewMarkydownRenderer(&b), options...)
+	return b.String()
+}
+
+// MarkydownRenderer is a Processor that renders a Markydown document as
+// Markydown, writing the results to an io.Writer. In other words, it formats
+// Markydown documents in a canonical way.
+//
+// Headings get a single space after their `#`s, paragraphs are separated by
+// exactly one blank line and re-wrapped at Width columns, bulleted lists use
+// `+` as bullet, ordered lists are numbered sequentially, nested lists are
+// indented by four spaces, and every line of block quotes gets a `> `.
+// Characters that would otherwise have a special meaning are escaped with
+// backslashes, non-breaking spaces are written as escaped spaces, and code
+// spans are delimited by as few backticks as possible (code blocks, by as few
+// as possible, but at least three). Images are never broken across lines.
+// Emphasis left open at the end of a paragraph is left open.
+//
+// MarkydownRenderer is a PositionedProcessor only to tell emphasis left open
+// from emphasis closed right at the end of a paragraph. It works without the
+// spans, too, but then leaves all emphasis at the end of paragraphs open.
+type MarkydownRenderer struct {
+	// Width is the maximum line width, in characters. Words longer than this
+	// are not broken, so they may exceed it. Zero (or less) disables wrapping.
+	// Headings are never wrapped.
+	Width int
+
+	w          io.Writer  // Where the output goes to
+	err        error      // The first error found while writing, if any
+	started    bool       // Have we written any paragraph yet?
+	segments   [][]string // Words of the current paragraph, one slice per line forced by line breaks
+	word       string     // The word being built
+	style      TextStyle  // The current text style
+	closeStyle bool       // Are there styles closed that we didn't write yet?
+	marked     bool       // Were style markers the last thing we wrote?
+	links      []string   // Stack of targets of the links we are in
+	lists      []textList // Stack of lists we are in
+	marker     string     // Marker (like a bullet) of the list item whose paragraph is next
+	tightItem  bool       // Is the next paragraph an item of a tight list that needs no blank line before it?
+	codeInfo   string     // Info string of the next code block
+	codeBlock  bool       // Are we in a code block?
+	code       string     // Contents of the current code block
+	quotes     textQuotes // The block quotes we are in
+	span       Span       // The span of the input that originated the upcoming call
+}
+
+// NewMarkydownRenderer creates a new MarkydownRenderer that writes its output
+// to w, wrapping lines at 80 columns.
+func NewMarkydownRenderer(w io.Writer) *MarkydownRenderer {
+	return &MarkydownRenderer{
+		Width: 80,
+		w:     w,
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *MarkydownRenderer) Err() error {
+	return r.err
+}
+
+// StartDocument implements the Processor interface.
+func (r *MarkydownRenderer) StartDocument() {
+	r.started = false
+	r.style = TextStyleRegular
+	r.closeStyle = false
+	r.marked = false
+	r.links = nil
+	r.lists = nil
+	r.marker = ""
+	r.tightItem = false
+	r.codeInfo = ""
+	r.quotes = textQuotes{}
+}
+
+// EndDocument implements the Processor interface.
+func (r *MarkydownRenderer) EndDocument() {
+}
+
+// StartParagraph implements the Processor interface.
+func (r *MarkydownRenderer) StartParagraph(parType ParType) {
+	r.segments = [][]string{nil}
+	r.word = ""
+	r.codeBlock = parType == ParTypeCodeBlock
+	r.code = ""
+}
+
+// EndParagraph implements the Processor interface.
+func (r *MarkydownRenderer) EndParagraph(parType ParType) {
+	// Styles still open are closed by the parser at the end of the paragraph,
+	// so there is no need to write markers for them.
+	r.closeStyle = false
+	r.marked = false
+	r.style = TextStyleRegular
+	r.endWord()
+
+	if r.started && !r.tightItem {
+		r.write(r.quotes.separator())
+	}
+	r.started = true
+	r.tightItem = false
+	r.quotes.paragraph()
+
+	if r.codeBlock {
+		r.write(r.quotes.quote(formatCodeBlock(r.codeInfo, r.code)))
+		r.codeInfo = ""
+		r.codeBlock = false
+		r.segments = nil
+		return
+	}
+
+	indent := 0
+	firstPrefix := ""
+	width := 0
+
+	switch {
+	case parType.HeadingLevel() > 0:
+		firstPrefix = strings.Repeat("#", parType.HeadingLevel()) + " "
+
+	case r.marker != "":
+		l := r.lists[len(r.lists)-1]
+		indent = l.textIndent
+		firstPrefix = strings.Repeat(" ", l.indent) + r.marker
+	}
+
+	if r.Width > 0 && parType.HeadingLevel() == 0 {
+		width = r.Width - indent - len(r.quotes.prefix())
+		if width < 1 {
+			width = 1
+		}
+	}
+
+	for i, line := range wrapMarkydown(r.segments, width) {
+		prefix := strings.Repeat(" ", indent)
+		if len(line) == 0 {
+			prefix = "" // A line break ended the paragraph; spaces would be trailing
+		}
+
+		if i == 0 {
+			prefix = firstPrefix
+			if parType == ParTypeText && len(line) > 0 && strings.Trim(line[0], "#") == "" {
+				line[0] = "\\" + line[0] // Would be a heading
+			}
+			if parType == ParTypeText && len(line) > 0 && strings.HasPrefix(line[0], ">") {
+				line[0] = "\\" + line[0] // Would be a block quote
+			}
+		}
+
+		if len(line) > 0 && (i > 0 || parType == ParTypeText) && isMarkerWord(line[0]) {
+			line[0] = "\\" + line[0] // Would be a list item
+		}
+
+		r.write(r.quotes.quote(prefix + strings.Join(line, " ") + "\n"))
+	}
+
+	r.marker = ""
+	r.segments = nil
+}
+
+// Fragment implements the Processor interface.
+func (r *MarkydownRenderer) Fragment(text string) {
+	if r.codeBlock {
+		r.code += text
+		return
+	}
+
+	r.flushStyle()
+
+	var b strings.Builder
+	for _, c := range text {
+		if isEscape(c) || isEmphasis(c) || isLinkStart(c) || isLinkEnd(c) || isCodeDelimiter(c) || isHorizontalSpace(c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	r.word += b.String()
+}
+
+// SpecialToken implements the Processor interface.
+func (r *MarkydownRenderer) SpecialToken(token SpecialToken) {
+	r.flushStyle()
+
+	if token == SpecialTokenNonBreakingSpace {
+		r.word += "\\ "
+		return
+	}
+
+	if token == SpecialTokenLineBreak {
+		r.word += "\\"
+		r.endWord()
+		r.segments = append(r.segments, nil)
+		return
+	}
+
+	r.endWord()
+}
+
+// ChangeTextStyle implements the Processor interface.
+func (r *MarkydownRenderer) ChangeTextStyle(style TextStyle) {
+	marked := r.marked
+	r.flushStyle()
+
+	// Styles left open at the end of a paragraph are closed by the parser
+	// with an empty span (and maybe all at once, which no marker could do).
+	// We leave them open, too, by waiting to see if the paragraph is really
+	// ending, so that the markers are not needed. Same if styles are closed
+	// right after being opened, as the closing markers would be read together
+	// with the opening ones, as a single different marker.
+	leftOpen := r.span.StartOffset == r.span.EndOffset
+	if style == TextStyleRegular && (marked || leftOpen) {
+		r.closeStyle = true
+		return
+	}
+
+	r.writeStyleMarkers(style)
+}
+
+// SourceSpan implements the PositionedProcessor interface.
+func (r *MarkydownRenderer) SourceSpan(span Span) {
+	r.span = span
+}
+
+// StartLink implements the Processor interface.
+func (r *MarkydownRenderer) StartLink(target string) {
+	r.flushStyle()
+	r.links = append(r.links, target)
+
+	// `![` would start an image
+	if strings.HasSuffix(r.word, "!") {
+		r.word = strings.TrimSuffix(r.word, "!") + "\\!"
+	}
+
+	r.word += "["
+}
+
+// EndLink implements the Processor interface.
+func (r *MarkydownRenderer) EndLink() {
+	r.flushStyle()
+
+	if len(r.links) == 0 {
+		return
+	}
+
+	target := r.links[len(r.links)-1]
+	r.links = r.links[:len(r.links)-1]
+
+	r.word += "](" + escapeLinkTarget(target) + ")"
+}
+
+// Code implements the CodeProcessor interface.
+func (r *MarkydownRenderer) Code(text string) {
+	r.flushStyle()
+	r.word += formatCodeSpan(text)
+}
+
+// CodeBlockInfo implements the CodeProcessor interface.
+func (r *MarkydownRenderer) CodeBlockInfo(info string) {
+	r.codeInfo = info
+}
+
+// StartList implements the ListProcessor interface.
+func (r *MarkydownRenderer) StartList(list ListInfo) {
+	indent := 0
+	if len(r.lists) > 0 {
+		indent = r.lists[len(r.lists)-1].indent + listNestingIndent
+	}
+
+	r.lists = append(r.lists, textList{
+		info:   list,
+		indent: indent,
+	})
+}
+
+// EndList implements the ListProcessor interface.
+func (r *MarkydownRenderer) EndList(list ListInfo) {
+	if len(r.lists) > 0 {
+		r.lists = r.lists[:len(r.lists)-1]
+	}
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *MarkydownRenderer) StartListItem(list ListInfo) {
+	if len(r.lists) == 0 {
+		return
+	}
+
+	l := &r.lists[len(r.lists)-1]
+
+	if l.info.Kind == ListKindOrdered {
+		r.marker = strconv.Itoa(l.info.Start+l.items) + ". "
+	} else {
+		r.marker = "+ "
+	}
+
+	// A blank line before any item but the first one makes the list loose, so
+	// we must not write one for tight lists. Same for the first item of a
+	// nested list, just to keep things consistent.
+	r.tightItem = l.info.Tight && (l.items > 0 || l.info.Depth > 1)
+
+	l.items++
+	l.textIndent = l.indent + utf8.RuneCountInString(r.marker)
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *MarkydownRenderer) EndListItem(list ListInfo) {
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *MarkydownRenderer) StartQuote() {
+	r.quotes.start()
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *MarkydownRenderer) EndQuote() {
+	if r.quotes.empty {
+		// Empty quotes must be written too, or they would be lost
+		if r.started {
+			r.write(r.quotes.separator())
+		}
+		r.started = true
+		r.write(r.quotes.quote("\n"))
+		r.quotes.paragraph()
+	}
+
+	r.quotes.end()
+}
+
+// Image implements the ImageProcessor interface. The alternative text is
+// written as is, but with its special characters escaped.
+func (r *MarkydownRenderer) Image(source, alt string) {
+	r.flushStyle()
+
+	var b strings.Builder
+	for _, c := range alt {
+		if isEscape(c) || isEmphasis(c) || isLinkStart(c) || isLinkEnd(c) || isCodeDelimiter(c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	r.word += "![" + b.String() + "](" + escapeLinkTarget(source) + ")"
+}
+
+// writeStyleMarkers writes the emphasis markers needed to change from the
+// current text style to a given one.
+func (r *MarkydownRenderer) writeStyleMarkers(style TextStyle) {
+	changed := r.style ^ style
+	r.style = style
+
+	if changed.Has(TextStyleStrong) {
+		r.word += "**"
+	}
+	if changed.Has(TextStyleEmphasis) {
+		r.word += "*"
+	}
+
+	r.marked = changed != TextStyleRegular
+}
+
+// flushStyle writes the markers for closed styles, if we were waiting to see if
+// they were necessary. (They are, since the paragraph didn't end right after
+// them.) Must be called before writing anything but style markers.
+func (r *MarkydownRenderer) flushStyle() {
+	if r.closeStyle {
+		r.closeStyle = false
+		r.writeStyleMarkers(TextStyleRegular)
+	}
+	r.marked = false
+}
+
+// endWord adds the word being built (if any) to the current paragraph.
+func (r *MarkydownRenderer) endWord() {
+	if r.word == "" || r.segments == nil {
+		r.word = ""
+		return
+	}
+
+	last := len(r.segments) - 1
+	r.segments[last] = append(r.segments[last], r.word)
+	r.word = ""
+}
+
+// write writes s to the output, unless a previous write failed.
+func (r *MarkydownRenderer) write(s string) {
+	if r.err != nil || len(s) == 0 {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
+
+// escapeLinkTarget escapes a link target (or image source) to be written
+// between parentheses.
+func escapeLinkTarget(target string) string {
+	var b strings.Builder
+	for _, c := range target {
+		if isEscape(c) || isLinkTargetEnd(c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// isMarkerWord checks if a given word would be taken as a list item marker
+// when found at the start of a line.
+func isMarkerWord(word string) bool {
+	_, _, length := listMarker(word + " ")
+	return length == len(word)
+}
+
+// wrapMarkydown wraps a paragraph of Markydown, given as a list of words for
+// each of its forced lines, so that lines are at most width characters wide
+// (unless a single word is wider than that). If width is zero or less, lines
+// are not wrapped.
+func wrapMarkydown(segments [][]string, width int) [][]string {
+	var textSegments [][]textWord
+	for _, words := range segments {
+		var textWords []textWord
+		for _, word := range words {
+			textWords = append(textWords, textWord{{text: word}})
+		}
+		textSegments = append(textSegments, textWords)
+	}
+
+	var lines [][]string
+	for _, textLine := range wrapText(textSegments, width) {
+		var line []string
+		for _, word := range textLine {
+			line = append(line, word[0].text)
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
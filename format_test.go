@@ -0,0 +1,152 @@
+package markydown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// formatTestInputs are inputs used to check if formatting a document preserves
+// its meaning.
+var formatTestInputs = []string{
+	"Just text.",
+	"# Unbe*lie*vable!",
+	"#### Four\n\n###### Six",
+	"*Switching**directly** to another style*",
+	"**Bold *and italic***, ***both** first*",
+	"*Unclosed **styles\n\nare closed",
+	"****Nothing** at all**",
+	"Escaped \\*stars\\*, \\[brackets\\] and \\\\backslashes",
+	"Non-breaking 100\\ kg and\\\ttabs",
+	"\\# Not a heading\n\n\\####### Nor this\n\n\\+ Nor an item\n\n42\\. Nor this",
+	"Not\n+ an item\nnor\n1. this",
+	"A [link (with parens)](http://x.com/a_(b)) and [an \\[escaped\\] one](\\\\)",
+	"[Not\n\na](link)",
+	"+ Tight\n    1. and\n    2. nested\n+ list",
+	"+ 1\n    + a\n    + b\n\n+ 2",
+	"+ 1\n\n    + a\n\n    + b\n+ 2",
+	"+ One\n1. Two\n+ Three",
+	"+ Item with \\\n  hard break\n+ Other",
+	"+ a\\\n\n+ c",
+	"+ \\\n2. é#### \\\n\n+ ",
+	"100. Long\n\n    + marker",
+	"3. Three\n\n    + \\+ Plus\n\n    + 1\\. One",
+	"+ Item\n\nText\n\n+ Another list",
+	"```go\nfunc  main() {\n\n\t```\n}\n```\n\n```\n```\n```\nUnclosed\n\n",
+	"Code: `*a*` ``b ` c`` `` `d` `` `  e  ` `f\n  g` \\`h\\` [`]`](i)",
+	"> # Quote\n>\n>> Nested\nlazy\n>\n> + Item\n>     + Nested\n>\n> ```\n>\n> ```",
+	"> One\n\n> > Two\n\n>\n\n> >\n\n> \\> Not nested\n\n\\> Not quoted",
+	"a *\n\n3 * 7 = 21\n\n!*\n\n*a **\n\n***",
+	"x y&\t\\",
+	"Images: ![A *big*\n\\[cat\\]](c\\)t.png) ![](x)![`a]`\\\\](y) Wow\\![not an image](z)",
+}
+
+// Tests if formatting a document preserves its meaning, and if formatting is
+// idempotent.
+func TestFormatRoundTrip(t *testing.T) {
+	inputs := append(append([]string{}, readerTestInputs...), formatTestInputs...)
+
+	for _, input := range inputs {
+		assertFormatRoundTrip(t, input)
+	}
+}
+
+// Tests if formatting preserves the meaning of documents made of all sequences
+// of up to three tricky pieces, which may combine in unexpected ways.
+func TestFormatRoundTripTricky(t *testing.T) {
+	pieces := []string{
+		"x", " ", "\t", "\n", "\n\n", "\r\n", "\\", "\\\n", "\\ ", "*", "**",
+		"+ ", "1. ", "# ", "> ", "    ", "[", "](t)", "![", "`", "```", "&", "!",
+	}
+
+	inputs := []string{""}
+	for i := 0; i < 3; i++ {
+		var longer []string
+		for _, input := range inputs {
+			for _, piece := range pieces {
+				longer = append(longer, input+piece)
+				assertFormatRoundTrip(t, input+piece)
+			}
+		}
+		inputs = longer
+	}
+}
+
+// assertFormatRoundTrip checks if formatting input preserves its meaning, and
+// if formatting it again changes nothing.
+func assertFormatRoundTrip(t *testing.T, input string) {
+	formatted := Format(input)
+
+	expected := &listProcessor{}
+	Parse(input, expected)
+
+	actual := &listProcessor{}
+	Parse(formatted, actual)
+
+	assert.Equal(t, actual.res, expected.res)
+	assert.Equal(t, Format(formatted), formatted)
+}
+
+// Tests if formatted documents look as expected.
+func TestFormat(t *testing.T) {
+	testData := map[string]string{
+		"":                            "",
+		"  Just   text  ":             "Just text\n",
+		"#   Title\n\n\n\nParagraph.": "# Title\n\nParagraph.\n",
+		"One\\\n   two":               "One\\\ntwo\n",
+		"a *b **c*** d":               "a *b **c*** d\n",
+		"*a **b":                      "*a **b\n",
+		"a *":                         "a *\n",
+		"!*":                          "!*\n",
+		"3 * 7 = 21":                  "3 * 7 = 21\n",
+		"**Closed** *too*":            "**Closed** *too*\n",
+		"x y&\t\\":                    "x y&\n",
+		"Non-breaking 100\\ kg":       "Non-breaking 100\\ kg\n",
+		"\\# Not a heading":           "\\# Not a heading\n",
+		"[Link](a\\)b)":               "[Link](a\\)b)\n",
+		"![ *A*  cat ](c.png)":        "![A cat](c.png)\n",
+		"Wow\\![link](x)":             "Wow\\![link](x)\n",
+
+		"+ One\n\n+ Two":              "+ One\n\n+ Two\n",
+		"+ One\n+ Two\n\nText":        "+ One\n+ Two\n\nText\n",
+		"5. Five\n3. Six":             "5. Five\n6. Six\n",
+		"+ One\n\n\t1. Nested\n+ Two": "+ One\n    1. Nested\n+ Two\n",
+		"+ a\\\n\n+ c":                "+ a\\\n\n\n+ c\n",
+
+		">Quote\nlazy\n>\n>>Nested\n\n>":           "> Quote lazy\n>\n> > Nested\n\n>\n",
+		"> +  Item\n>\n>   ```\n>   code\n>   ```": "> + Item\n>\n> ```\n> code\n> ```\n",
+		"\\> Not quoted\n\n\\>":                    "\\> Not quoted\n\n\\>\n",
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, Format(input), expected)
+	}
+}
+
+// Tests wrapping formatted documents.
+func TestMarkydownRendererWrapping(t *testing.T) {
+	input := "# A heading that is never wrapped\n\n" +
+		"The quick brown fox jumps over the lazy dog.\n\n" +
+		"+ The quick brown fox jumps\n\n    10. over the lazy dog\n\n" +
+		"> The quick brown fox"
+
+	expected := "# A heading that is never wrapped\n\n" +
+		"The quick brown\nfox jumps over\nthe lazy dog.\n\n" +
+		"+ The quick\n  brown fox\n  jumps\n    10. over the\n        lazy dog\n\n" +
+		"> The quick\n> brown fox\n"
+
+	var buf bytes.Buffer
+	r := NewMarkydownRenderer(&buf)
+	r.Width = 16
+	Parse(input, r)
+
+	assert.Equal(t, buf.String(), expected)
+}
+
+// Tests if write errors are reported.
+func TestMarkydownRendererError(t *testing.T) {
+	r := NewMarkydownRenderer(failingWriter{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
@@ -1,6 +1,7 @@
 package markydown
 
 import (
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -25,6 +26,15 @@ func (p *parser) consumeRawSpaces() {
 	}
 }
 
+// indentation returns the indentation of the current input line up to the
+// current position in the input, in columns. Tabs move to the next multiple of
+// four columns.
+func (p *parser) indentation() int {
+	consumed := p.buf[:len(p.buf)-len(p.input)]
+	indent, _ := indentationOf(consumed[strings.LastIndexAny(consumed, "\n\r")+1:])
+	return indent
+}
+
 // consumeRawHorizontalSpaces chomps horizontal spaces from the input.
 func (p *parser) consumeRawHorizontalSpaces() {
 	for len(p.input) > 0 {
@@ -35,7 +45,7 @@ func (p *parser) consumeRawHorizontalSpaces() {
 		}
 
 		p.input = p.input[w:]
-		p.frag = p.input
+		p.startFragment()
 	}
 }
 
@@ -44,7 +54,7 @@ func (p *parser) consumeRawHorizontalSpaces() {
 func (p *parser) consumeRawSpacesWithinParagraph() {
 	p.consumeRawHorizontalSpaces()
 	r, w := utf8.DecodeRuneInString(p.input)
-	if isNewLine(r) {
+	if isNewLine(r) && !p.isParagraphEnd(p.input) {
 		p.input = p.input[w:]
 
 		firstNewLine := r
@@ -56,18 +66,71 @@ func (p *parser) consumeRawSpacesWithinParagraph() {
 		p.consumeRawHorizontalSpaces()
 	}
 
-	p.frag = p.input
+	p.startFragment()
 }
 
 // paragraphGoesOn tests whether the current paragraph goes on or if we are at
-// its end.
+// its end. A rogue backslash at the end of the input is ignored, so it doesn't
+// make the paragraph go on.
 func (p *parser) paragraphGoesOn() bool {
 	if len(p.input) == 0 {
 		return false
 	}
 
 	r, _ := utf8.DecodeRuneInString(p.input)
-	return !isNewLine(r)
+	return !isNewLine(r) && !isRogueEscape(p.input)
+}
+
+// isRogueEscape tests if s is just an escape character, which therefore escapes
+// nothing.
+func isRogueEscape(s string) bool {
+	r, w := utf8.DecodeRuneInString(s)
+	return isEscape(r) && w == len(s)
+}
+
+// isParagraphEnd tests if a given string, which is assumed to be somewhere
+// within the current paragraph, starts with something that ends the paragraph:
+// a paragraph break or, if we are in a list item, a new line followed by
+// another list item.
+func (p *parser) isParagraphEnd(s string) bool {
+	if isParagraphBreak(s) {
+		return true
+	}
+
+	if !p.inListItem {
+		return false
+	}
+
+	r, w := utf8.DecodeRuneInString(s)
+	if !isNewLine(r) {
+		return false
+	}
+
+	_, _, markerLen := listMarker(strings.TrimLeftFunc(skipNewLine(s[w:], r), isHorizontalSpace))
+	return markerLen > 0
+}
+
+// skipNewLine skips the second rune of a CRLF or LFCR pair, if it is at the
+// start of s. firstNewLine is the new line rune just before s.
+func skipNewLine(s string, firstNewLine rune) string {
+	r, w := utf8.DecodeRuneInString(s)
+	if isNewLine(r) && r != firstNewLine {
+		return s[w:]
+	}
+	return s
+}
+
+// isParagraphBreak tests if a given string starts with a paragraph break: a
+// new line followed by a blank line.
+func isParagraphBreak(s string) bool {
+	r, w := utf8.DecodeRuneInString(s)
+	if !isNewLine(r) {
+		return false
+	}
+
+	s = strings.TrimLeftFunc(skipNewLine(s[w:], r), isHorizontalSpace)
+	r, _ = utf8.DecodeRuneInString(s)
+	return isNewLine(r)
 }
 
 // isHardLineBreakAhead tests if we have a hard line break just ahead.
@@ -0,0 +1,341 @@
+package markydown
+
+import (
+	"html"
+	"io"
+	"strconv"
+)
+
+// HTMLRenderer is a Processor that renders a Markydown document as HTML,
+// writing the results to an io.Writer.
+//
+// Text and link targets are properly escaped. HTMLRenderer is a ListProcessor,
+// so lists (including nested ones) are rendered as `<ul>` or `<ol>` elements.
+// The contents of items of loose lists are wrapped in `<p>` elements. It is
+// also a CodeProcessor, rendering code spans as `<code>` elements and code
+// blocks as `<pre>` elements (with a `language-*` class, as suggested by the
+// HTML specification, if the info string says what the language is). Block
+// quotes are rendered as `<blockquote>` elements, and images as `<img>`
+// elements.
+type HTMLRenderer struct {
+	// FullDocument tells whether the output shall be a full HTML document
+	// (that is, wrapped in `<html>` and `<body>` elements). If false, only an
+	// HTML fragment with the document contents is generated.
+	FullDocument bool
+
+	w          io.Writer   // Where the output goes to
+	err        error       // The first error found while writing, if any
+	textStyle  TextStyle   // The current text style
+	openStyles []TextStyle // Styles whose HTML elements are open, in the order they were opened
+	links      []htmlLink  // Stack of links we are in
+	lists      []ListInfo  // Stack of lists we are in
+	codeInfo   string      // Info string of the next code block
+	codeBlock  bool        // Are we in a code block?
+}
+
+// htmlLink is a link whose `<a>` element is open.
+type htmlLink struct {
+	target string // The link target
+	styles int    // Number of style elements that were open when the link started
+}
+
+// NewHTMLRenderer creates a new HTMLRenderer that writes its output to w. By
+// default, it generates just an HTML fragment; set FullDocument to true to get
+// a full HTML document.
+func NewHTMLRenderer(w io.Writer) *HTMLRenderer {
+	return &HTMLRenderer{
+		w:         w,
+		textStyle: TextStyleRegular,
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *HTMLRenderer) Err() error {
+	return r.err
+}
+
+// StartDocument implements the Processor interface.
+func (r *HTMLRenderer) StartDocument() {
+	r.textStyle = TextStyleRegular
+	r.openStyles = nil
+	r.links = nil
+	r.lists = nil
+	r.codeInfo = ""
+	r.codeBlock = false
+
+	if r.FullDocument {
+		r.write("<html>\n<body>\n")
+	}
+}
+
+// EndDocument implements the Processor interface.
+func (r *HTMLRenderer) EndDocument() {
+	if r.FullDocument {
+		r.write("</body>\n</html>\n")
+	}
+}
+
+// StartParagraph implements the Processor interface.
+func (r *HTMLRenderer) StartParagraph(parType ParType) {
+	if parType == ParTypeCodeBlock {
+		r.codeBlock = true
+		r.write("<pre><code")
+		if language := codeLanguage(r.codeInfo); language != "" {
+			r.write(" class=\"language-" + html.EscapeString(language) + "\"")
+		}
+		r.write(">")
+		r.codeInfo = ""
+		return
+	}
+
+	if !r.isTightListItem(parType) {
+		r.write("<" + htmlParagraphTag(parType) + ">")
+	}
+}
+
+// EndParagraph implements the Processor interface.
+func (r *HTMLRenderer) EndParagraph(parType ParType) {
+	r.closeStylesFor(TextStyleRegular)
+
+	if parType == ParTypeCodeBlock {
+		r.codeBlock = false
+		r.write("</code></pre>\n")
+		return
+	}
+
+	if !isListParType(parType) {
+		r.write("</" + htmlParagraphTag(parType) + ">\n")
+	} else if !r.isTightListItem(parType) {
+		r.write("</p>")
+	}
+}
+
+// Fragment implements the Processor interface.
+func (r *HTMLRenderer) Fragment(text string) {
+	r.openStylesFor(r.textStyle)
+	r.write(html.EscapeString(text))
+
+	if r.codeBlock {
+		r.write("\n")
+	}
+}
+
+// SpecialToken implements the Processor interface.
+func (r *HTMLRenderer) SpecialToken(token SpecialToken) {
+	r.openStylesFor(r.textStyle)
+
+	switch token {
+	case SpecialTokenSpace:
+		r.write(" ")
+	case SpecialTokenNonBreakingSpace:
+		r.write("&nbsp;")
+	case SpecialTokenLineBreak:
+		r.write("<br>")
+	}
+}
+
+// ChangeTextStyle implements the Processor interface.
+//
+// The HTML elements for the new styles are opened only when some content is
+// written, so that we don't generate empty elements.
+func (r *HTMLRenderer) ChangeTextStyle(style TextStyle) {
+	r.closeStylesFor(style)
+	r.textStyle = style
+}
+
+// Code implements the CodeProcessor interface.
+func (r *HTMLRenderer) Code(text string) {
+	r.openStylesFor(r.textStyle)
+	r.write("<code>" + html.EscapeString(text) + "</code>")
+}
+
+// CodeBlockInfo implements the CodeProcessor interface.
+func (r *HTMLRenderer) CodeBlockInfo(info string) {
+	r.codeInfo = info
+}
+
+// StartList implements the ListProcessor interface.
+func (r *HTMLRenderer) StartList(list ListInfo) {
+	r.lists = append(r.lists, list)
+
+	if list.Depth > 1 {
+		r.write("\n")
+	}
+
+	switch {
+	case list.Kind == ListKindBulleted:
+		r.write("<ul>\n")
+	case list.Start == 1:
+		r.write("<ol>\n")
+	default:
+		r.write("<ol start=\"" + strconv.Itoa(list.Start) + "\">\n")
+	}
+}
+
+// EndList implements the ListProcessor interface.
+func (r *HTMLRenderer) EndList(list ListInfo) {
+	if len(r.lists) > 0 {
+		r.lists = r.lists[:len(r.lists)-1]
+	}
+
+	if list.Kind == ListKindBulleted {
+		r.write("</ul>\n")
+	} else {
+		r.write("</ol>\n")
+	}
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *HTMLRenderer) StartListItem(list ListInfo) {
+	r.write("<li>")
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *HTMLRenderer) EndListItem(list ListInfo) {
+	r.write("</li>\n")
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *HTMLRenderer) StartQuote() {
+	r.write("<blockquote>\n")
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *HTMLRenderer) EndQuote() {
+	r.write("</blockquote>\n")
+}
+
+// Image implements the ImageProcessor interface.
+func (r *HTMLRenderer) Image(source, alt string) {
+	r.openStylesFor(r.textStyle)
+	r.write("<img src=\"" + html.EscapeString(source) + "\" alt=\"" + html.EscapeString(alt) + "\">")
+}
+
+// StartLink implements the Processor interface.
+func (r *HTMLRenderer) StartLink(target string) {
+	r.openStylesFor(r.textStyle)
+	r.links = append(r.links, htmlLink{target: target, styles: len(r.openStyles)})
+	r.write("<a href=\"" + html.EscapeString(target) + "\">")
+}
+
+// EndLink implements the Processor interface.
+//
+// Style elements opened within the link are closed before it, and will be
+// reopened by the next openStylesFor.
+func (r *HTMLRenderer) EndLink() {
+	if len(r.links) == 0 {
+		return
+	}
+
+	r.closeStyles(r.links[len(r.links)-1].styles)
+	r.links = r.links[:len(r.links)-1]
+	r.write("</a>")
+}
+
+// isTightListItem checks if a paragraph of a given type is an item of a tight
+// list. (Paragraphs that are list items but are not within any list, which
+// happens only if the Processor calls are not coming from the parser, are
+// treated as items of tight lists.)
+func (r *HTMLRenderer) isTightListItem(parType ParType) bool {
+	return isListParType(parType) && (len(r.lists) == 0 || r.lists[len(r.lists)-1].Tight)
+}
+
+// closeStylesFor closes the HTML elements of the open styles that are not part
+// of style. Elements opened after them are closed too (to keep the HTML
+// properly nested): styles will be reopened by the next openStylesFor, and
+// links are reopened right away, as new `<a>` elements.
+func (r *HTMLRenderer) closeStylesFor(style TextStyle) {
+	keep := 0
+	for keep < len(r.openStyles) && style.Has(r.openStyles[keep]) {
+		keep++
+	}
+
+	r.closeStyles(keep)
+}
+
+// closeStyles closes the HTML elements of the open styles but the first keep
+// ones, along with (and then reopening) the links opened after them.
+func (r *HTMLRenderer) closeStyles(keep int) {
+	closed := 0 // Number of links closed
+	for i := len(r.openStyles) - 1; i >= keep; i-- {
+		for closed < len(r.links) && r.links[len(r.links)-1-closed].styles > i {
+			r.write("</a>")
+			closed++
+		}
+		r.write(htmlStyleClosingTag(r.openStyles[i]))
+	}
+
+	r.openStyles = r.openStyles[:keep]
+
+	for i := len(r.links) - closed; i < len(r.links); i++ {
+		r.links[i].styles = keep
+		r.write("<a href=\"" + html.EscapeString(r.links[i].target) + "\">")
+	}
+}
+
+// openStylesFor opens the HTML elements of the styles in style that are not
+// open yet.
+func (r *HTMLRenderer) openStylesFor(style TextStyle) {
+	for _, s := range textStyles {
+		if style.Has(s) && !r.isStyleOpen(s) {
+			r.write(htmlStyleOpeningTag(s))
+			r.openStyles = append(r.openStyles, s)
+		}
+	}
+}
+
+// isStyleOpen checks if the HTML element of a given style is open.
+func (r *HTMLRenderer) isStyleOpen(style TextStyle) bool {
+	for _, s := range r.openStyles {
+		if s == style {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes s to the output, unless a previous write failed.
+func (r *HTMLRenderer) write(s string) {
+	if r.err != nil || len(s) == 0 {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
+
+// htmlParagraphTag returns the name of the HTML element used to render a
+// paragraph of a given type. (For list items, this is the element used within
+// the `<li>` of loose lists.)
+func htmlParagraphTag(parType ParType) string {
+	if level := parType.HeadingLevel(); level > 0 {
+		return "h" + strconv.Itoa(level)
+	}
+
+	return "p"
+}
+
+// htmlStyleOpeningTag returns the HTML opening tag used to start text in a
+// given (single, not combined) style.
+func htmlStyleOpeningTag(style TextStyle) string {
+	switch style {
+	case TextStyleEmphasis:
+		return "<em>"
+	case TextStyleStrong:
+		return "<strong>"
+	default:
+		return ""
+	}
+}
+
+// htmlStyleClosingTag returns the HTML closing tag used to end text in a given
+// (single, not combined) style.
+func htmlStyleClosingTag(style TextStyle) string {
+	switch style {
+	case TextStyleEmphasis:
+		return "</em>"
+	case TextStyleStrong:
+		return "</strong>"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,97 @@
+package markydown
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// renderHTML renders a Markydown document to an HTML string.
+func renderHTML(input string, fullDocument bool) string {
+	var buf bytes.Buffer
+	r := NewHTMLRenderer(&buf)
+	r.FullDocument = fullDocument
+	Parse(input, r)
+	return buf.String()
+}
+
+// Tests rendering HTML fragments.
+func TestHTMLRendererFragments(t *testing.T) {
+	testData := map[string]string{
+		"":                    "",
+		"Hello, *world*!":     "<p>Hello, <em>world</em>!</p>\n",
+		"# One\n\n## **Two**": "<h1>One</h1>\n<h2><strong>Two</strong></h2>\n",
+		"### Three\\\nlines":  "<h3>Three<br>lines</h3>\n",
+		"###### Six":          "<h6>Six</h6>\n",
+		"100\\ kg":            "<p>100&nbsp;kg</p>\n",
+		"Use `<b>` and *`a`*": "<p>Use <code>&lt;b&gt;</code> and <em><code>a</code></em></p>\n",
+		"```go main\n<a>\n\n```\n\n```\n```": "<pre><code class=\"language-go\">&lt;a&gt;\n\n</code></pre>\n" +
+			"<pre><code></code></pre>\n",
+
+		// Escaping
+		"a < b && c > d":              "<p>a &lt; b &amp;&amp; c &gt; d</p>\n",
+		"[\"quoted\"](x?a=1&b=\"2\")": "<p><a href=\"x?a=1&amp;b=&#34;2&#34;\">&#34;quoted&#34;</a></p>\n",
+
+		// Lists
+		"+ One\n\n+ Two": "<ul>\n<li><p>One</p></li>\n<li><p>Two</p></li>\n</ul>\n",
+		"+ One\n+ Two":   "<ul>\n<li>One</li>\n<li>Two</li>\n</ul>\n",
+		"+ One\n\nText\n\n+ Two": "<ul>\n<li>One</li>\n</ul>\n<p>Text</p>\n" +
+			"<ul>\n<li>Two</li>\n</ul>\n",
+		"+ One\n\n    + Two\n\n+ Three": "<ul>\n<li><p>One</p>\n<ul>\n<li>Two</li>\n</ul>\n</li>\n" +
+			"<li><p>Three</p></li>\n</ul>\n",
+		"+ One\n    + Two\n+ Three": "<ul>\n<li>One\n<ul>\n<li>Two</li>\n</ul>\n</li>\n" +
+			"<li>Three</li>\n</ul>\n",
+		"1. One\n2. Two": "<ol>\n<li>One</li>\n<li>Two</li>\n</ol>\n",
+		"3. Three":       "<ol start=\"3\">\n<li>Three</li>\n</ol>\n",
+
+		// Images
+		"*An ![\"image\"](a&b.png)*": "<p><em>An <img src=\"a&amp;b.png\" alt=\"&#34;image&#34;\"></em></p>\n",
+
+		// Quotes
+		"> # One\n>\n> > Two\nlazy\n\n>": "<blockquote>\n<h1>One</h1>\n<blockquote>\n<p>Two lazy</p>\n</blockquote>\n" +
+			"</blockquote>\n<blockquote>\n</blockquote>\n",
+
+		// Links and styles crossing each other
+		"[a *b](t) c*":     "<p><a href=\"t\">a <em>b</em></a><em> c</em></p>\n",
+		"*a [b* c](t)":     "<p><em>a <a href=\"t\">b</a></em><a href=\"t\"> c</a></p>\n",
+		"**a [*b** c*](t)": "<p><strong>a <a href=\"t\"><em>b</em></a></strong><a href=\"t\"><em> c</em></a></p>\n",
+
+		// Combined styles
+		"**a *b***":   "<p><strong>a <em>b</em></strong></p>\n",
+		"**a *b** c*": "<p><strong>a <em>b</em></strong><em> c</em></p>\n",
+		"***a** b*":   "<p><strong><em>a</em></strong><em> b</em></p>\n",
+
+		// Styles left open are closed at the end of each paragraph
+		"*One\n\nTwo":   "<p><em>One</em></p>\n<p>Two</p>\n",
+		"***a\n\nb *c*": "<p><strong><em>a</em></strong></p>\n<p>b <em>c</em></p>\n",
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, renderHTML(input, false), expected)
+	}
+}
+
+// Tests rendering full HTML documents.
+func TestHTMLRendererFullDocument(t *testing.T) {
+	assert.Equal(t, renderHTML("", true), "<html>\n<body>\n</body>\n</html>\n")
+	assert.Equal(t, renderHTML("+ Item", true),
+		"<html>\n<body>\n<ul>\n<li>Item</li>\n</ul>\n</body>\n</html>\n")
+}
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct{}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+// Tests if write errors are reported.
+func TestHTMLRendererError(t *testing.T) {
+	r := NewHTMLRenderer(failingWriter{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
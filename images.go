@@ -0,0 +1,156 @@
+package markydown
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ImageProcessor is a Processor that wants to know about images.
+//
+// Images are written like links, but with a `!` before them, as in
+// `![A cute cat](cat.png)`. The text between the brackets is the alternative
+// text of the image, reported as plain text: escapes are resolved, emphasis
+// markers are dropped (they never change the text style of the paragraph),
+// code spans become just their text and any sequence of spaces and new lines
+// becomes a single space. Images can be used as the text of links, as in
+// `[![Build status](badge.png)](http://ci.example.com)`.
+//
+// If the Processor passed to the parser implements this interface, images are
+// reported by calls to Image. Otherwise, their alternative texts are reported
+// as regular calls to Fragment.
+type ImageProcessor interface {
+	Processor
+
+	// Image is called for each image, with its source (the part between
+	// parentheses) and its alternative text.
+	Image(source, alt string)
+}
+
+// lookAheadForImage checks if the `!` we just found starts an image. If so,
+// the whole image is consumed, its source and alternative text are stored in
+// the parser, and true is returned. Otherwise, no input is consumed and false
+// is returned.
+func (p *parser) lookAheadForImage() bool {
+	r, w := utf8.DecodeRuneInString(p.input)
+	if !isLinkStart(r) {
+		return false
+	}
+
+	// The image may be within the text of a link, whose target we must keep
+	input, linkTarget, linkTargetLen := p.input, p.linkTarget, p.linkTargetLen
+	defer func() { p.linkTarget, p.linkTargetLen = linkTarget, linkTargetLen }()
+
+	p.input = p.input[w:]
+
+	if !p.lookAheadForLink() {
+		p.input = input
+		return false
+	}
+
+	p.imageSource = p.linkTarget
+	p.imageAlt = p.plainText(p.input[:p.linkTextLen])
+
+	// `+3` accounts for the `]` and the parens around the source
+	p.input = p.input[p.linkTextLen+p.linkTargetLen+3:]
+
+	return true
+}
+
+// imageLength returns the length in bytes of the image at the start of input
+// (which starts with a `!`), or zero if there is no complete image there.
+// Unlike lookAheadForImage, this leaves the parser untouched and reports no
+// diagnostics.
+func (p *parser) imageLength(input string) int {
+	image := input
+	input = input[1:] // The `!`
+
+	if r, _ := utf8.DecodeRuneInString(input); !isLinkStart(r) {
+		return 0
+	}
+
+	textLen, ok := p.linkTextLength(input[1:])
+	if !ok {
+		return 0
+	}
+	input = input[textLen+2:] // `+2` accounts for the brackets
+
+	if r, _ := utf8.DecodeRuneInString(input); !isLinkTargetStart(r) {
+		return 0
+	}
+	input = input[1:]
+
+	for empty := true; ; empty = false {
+		r, w := utf8.DecodeRuneInString(input)
+
+		switch {
+		case len(input) == 0 || p.isParagraphEnd(input):
+			return 0
+
+		case isLinkTargetEnd(r):
+			if empty {
+				return 0
+			}
+			return len(image) - len(input) + w
+
+		case isEscape(r):
+			input = input[w:]
+			_, w = utf8.DecodeRuneInString(input)
+		}
+
+		input = input[w:]
+	}
+}
+
+// plainText converts a piece of paragraph contents to plain text, as used for
+// the alternative text of images.
+func (p *parser) plainText(s string) string {
+	var b strings.Builder
+
+	for len(s) > 0 {
+		r, w := utf8.DecodeRuneInString(s)
+
+		switch {
+		case isEscape(r):
+			// An escaped new line is just a space here, so we leave the new
+			// line alone
+			s = s[w:]
+			r, w = utf8.DecodeRuneInString(s)
+			if !isNewLine(r) {
+				b.WriteString(s[:w])
+				s = s[w:]
+			}
+
+		case isEmphasis(r):
+			s = s[w:]
+
+		case isCodeDelimiter(r):
+			text, length, fence := p.codeSpan(s)
+			if length == 0 {
+				b.WriteString(s[:fence])
+				s = s[fence:]
+			} else {
+				b.WriteString(text)
+				s = s[length:]
+			}
+
+		default:
+			b.WriteString(s[:w])
+			s = s[w:]
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// image reports an image to the ImageProcessor, or its alternative text as a
+// Fragment if the processor is not an ImageProcessor. The image must end at the
+// current offset and start at offset start.
+func (p *parser) image(start int, source, alt string) {
+	if p.imager != nil {
+		p.at(start, p.offset())
+		p.imager.Image(source, alt)
+	} else if alt != "" {
+		p.at(start, p.offset())
+		p.processor.Fragment(alt)
+	}
+}
@@ -0,0 +1,476 @@
+package markydown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSONRenderer is a Processor that serializes the sequence of calls it
+// receives as JSON, writing the results to an io.Writer. This allows parsing
+// a document once and processing it elsewhere (even in other languages); use
+// ReplayJSON to process it in Go.
+//
+// Each call is written as a JSON object in a line of its own, with the name of
+// the method in "event" and its parameter (if any) in another field, as in:
+//
+//	{"event":"StartParagraph","type":"H1"}
+//	{"event":"Fragment","text":"Hello"}
+//	{"event":"SpecialToken","token":"SP"}
+//	{"event":"ChangeTextStyle","style":["ST","EM"]}
+//	{"event":"StartLink","target":"http://example.com"}
+//	{"event":"Code","text":"x := 1"}
+//	{"event":"CodeBlockInfo","info":"go"}
+//	{"event":"Image","source":"cat.png","alt":"A cat"}
+//	{"event":"StartList","list":{"depth":1,"kind":"OL","start":1,"tight":true}}
+//
+// Paragraph types are "P", "H1" to "H6", "UL", "OL" and "CODE"; special
+// tokens are "SP" (space), "NL" (line break) and "NB" (non-breaking space);
+// text styles are lists of "ST" (strong) and "EM" (emphasis), empty for
+// regular text; and list kinds are "UL" and "OL".
+type JSONRenderer struct {
+	enc *json.Encoder // Writes the output
+	err error         // The first error found while writing, if any
+}
+
+// jsonEvent is a Processor call, as serialized by a JSONRenderer.
+type jsonEvent struct {
+	Event  string    `json:"event"`
+	Type   string    `json:"type,omitempty"`
+	Text   *string   `json:"text,omitempty"`
+	Token  string    `json:"token,omitempty"`
+	Style  *[]string `json:"style,omitempty"`
+	Target *string   `json:"target,omitempty"`
+	Info   *string   `json:"info,omitempty"`
+	Source *string   `json:"source,omitempty"`
+	Alt    *string   `json:"alt,omitempty"`
+	List   *jsonList `json:"list,omitempty"`
+}
+
+// jsonList is a ListInfo, as serialized by a JSONRenderer.
+type jsonList struct {
+	Depth int    `json:"depth"`
+	Kind  string `json:"kind"`
+	Start int    `json:"start"`
+	Tight bool   `json:"tight"`
+}
+
+// Names used to serialize things to JSON.
+var (
+	jsonParTypes = map[ParType]string{
+		ParTypeText:         "P",
+		ParTypeHeading1:     "H1",
+		ParTypeHeading2:     "H2",
+		ParTypeHeading3:     "H3",
+		ParTypeHeading4:     "H4",
+		ParTypeHeading5:     "H5",
+		ParTypeHeading6:     "H6",
+		ParTypeBulletedList: "UL",
+		ParTypeOrderedList:  "OL",
+		ParTypeCodeBlock:    "CODE",
+	}
+
+	jsonSpecialTokens = map[SpecialToken]string{
+		SpecialTokenSpace:            "SP",
+		SpecialTokenLineBreak:        "NL",
+		SpecialTokenNonBreakingSpace: "NB",
+	}
+
+	jsonTextStyles = map[TextStyle]string{
+		TextStyleStrong:   "ST",
+		TextStyleEmphasis: "EM",
+	}
+
+	jsonListKinds = map[ListKind]string{
+		ListKindBulleted: "UL",
+		ListKindOrdered:  "OL",
+	}
+)
+
+// NewJSONRenderer creates a new JSONRenderer that writes its output to w.
+func NewJSONRenderer(w io.Writer) *JSONRenderer {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONRenderer{
+		enc: enc,
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *JSONRenderer) Err() error {
+	return r.err
+}
+
+// StartDocument implements the Processor interface.
+func (r *JSONRenderer) StartDocument() {
+	r.write(jsonEvent{Event: "StartDocument"})
+}
+
+// EndDocument implements the Processor interface.
+func (r *JSONRenderer) EndDocument() {
+	r.write(jsonEvent{Event: "EndDocument"})
+}
+
+// StartParagraph implements the Processor interface.
+func (r *JSONRenderer) StartParagraph(parType ParType) {
+	r.write(jsonEvent{Event: "StartParagraph", Type: jsonParTypes[parType]})
+}
+
+// EndParagraph implements the Processor interface.
+func (r *JSONRenderer) EndParagraph(parType ParType) {
+	r.write(jsonEvent{Event: "EndParagraph", Type: jsonParTypes[parType]})
+}
+
+// Fragment implements the Processor interface.
+func (r *JSONRenderer) Fragment(text string) {
+	r.write(jsonEvent{Event: "Fragment", Text: &text})
+}
+
+// SpecialToken implements the Processor interface.
+func (r *JSONRenderer) SpecialToken(token SpecialToken) {
+	r.write(jsonEvent{Event: "SpecialToken", Token: jsonSpecialTokens[token]})
+}
+
+// ChangeTextStyle implements the Processor interface.
+func (r *JSONRenderer) ChangeTextStyle(style TextStyle) {
+	styles := []string{}
+	for _, s := range textStyles {
+		if style.Has(s) {
+			styles = append(styles, jsonTextStyles[s])
+		}
+	}
+
+	r.write(jsonEvent{Event: "ChangeTextStyle", Style: &styles})
+}
+
+// StartLink implements the Processor interface.
+func (r *JSONRenderer) StartLink(target string) {
+	r.write(jsonEvent{Event: "StartLink", Target: &target})
+}
+
+// EndLink implements the Processor interface.
+func (r *JSONRenderer) EndLink() {
+	r.write(jsonEvent{Event: "EndLink"})
+}
+
+// Code implements the CodeProcessor interface.
+func (r *JSONRenderer) Code(text string) {
+	r.write(jsonEvent{Event: "Code", Text: &text})
+}
+
+// CodeBlockInfo implements the CodeProcessor interface.
+func (r *JSONRenderer) CodeBlockInfo(info string) {
+	r.write(jsonEvent{Event: "CodeBlockInfo", Info: &info})
+}
+
+// StartList implements the ListProcessor interface.
+func (r *JSONRenderer) StartList(list ListInfo) {
+	r.write(jsonEvent{Event: "StartList", List: newJSONList(list)})
+}
+
+// EndList implements the ListProcessor interface.
+func (r *JSONRenderer) EndList(list ListInfo) {
+	r.write(jsonEvent{Event: "EndList", List: newJSONList(list)})
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *JSONRenderer) StartListItem(list ListInfo) {
+	r.write(jsonEvent{Event: "StartListItem", List: newJSONList(list)})
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *JSONRenderer) EndListItem(list ListInfo) {
+	r.write(jsonEvent{Event: "EndListItem", List: newJSONList(list)})
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *JSONRenderer) StartQuote() {
+	r.write(jsonEvent{Event: "StartQuote"})
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *JSONRenderer) EndQuote() {
+	r.write(jsonEvent{Event: "EndQuote"})
+}
+
+// Image implements the ImageProcessor interface.
+func (r *JSONRenderer) Image(source, alt string) {
+	r.write(jsonEvent{Event: "Image", Source: &source, Alt: &alt})
+}
+
+// write writes an event to the output, unless a previous write failed.
+func (r *JSONRenderer) write(event jsonEvent) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(event)
+}
+
+// newJSONList converts a ListInfo to its JSON representation.
+func newJSONList(list ListInfo) *jsonList {
+	return &jsonList{
+		Depth: list.Depth,
+		Kind:  jsonListKinds[list.Kind],
+		Start: list.Start,
+		Tight: list.Tight,
+	}
+}
+
+// ReplayJSON reads the sequence of Processor calls serialized by a
+// JSONRenderer from r, and makes the very same calls to processor. List
+// events are passed along only if processor is also a ListProcessor (and quote
+// events, only if it is a QuoteProcessor). Code spans are passed as calls to
+// Fragment if processor is not a CodeProcessor (in which case the info strings
+// of code blocks are skipped), and so are the alternative texts of images if it
+// is not an ImageProcessor.
+//
+// If reading from r fails or the input is not valid, ReplayJSON stops and
+// returns the error. (Unlike ParseReader, it doesn't call EndDocument in this
+// case.) Besides invalid events, this includes events before StartDocument or
+// after EndDocument, and events ending a document, paragraph, list, list item,
+// block quote or link that is not the innermost one started.
+func ReplayJSON(r io.Reader, processor Processor) error {
+	lister, _ := processor.(ListProcessor)
+	coder, _ := processor.(CodeProcessor)
+	quoter, _ := processor.(QuoteProcessor)
+	imager, _ := processor.(ImageProcessor)
+	dec := json.NewDecoder(r)
+	var nesting jsonNesting
+
+	for n := 1; ; n++ {
+		var event jsonEvent
+		err := dec.Decode(&event)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("event %d: %v", n, err)
+		}
+
+		if err = nesting.check(event.Event); err != nil {
+			return fmt.Errorf("event %d: %v", n, err)
+		}
+
+		if err = replayJSONEvent(event, processor, lister, coder, quoter, imager); err != nil {
+			return fmt.Errorf("event %d: %v", n, err)
+		}
+	}
+}
+
+// jsonNesting keeps track of the document, paragraphs, lists, list items,
+// block quotes and links started and not yet ended while replaying JSON
+// events, so that events out of order never reach the Processor.
+type jsonNesting struct {
+	open  []string // What is open, innermost last, like "Document" or "Link"
+	ended bool     // Did the document end?
+}
+
+// check checks if an event can come at the current point of the sequence of
+// events, and updates what is open accordingly.
+func (n *jsonNesting) check(event string) error {
+	switch {
+	case n.ended:
+		return fmt.Errorf("%s after EndDocument", event)
+	case len(n.open) == 0 && event != "StartDocument":
+		return fmt.Errorf("%s before StartDocument", event)
+	case len(n.open) > 0 && event == "StartDocument":
+		return errors.New("StartDocument within a document")
+	}
+
+	if strings.HasPrefix(event, "Start") {
+		n.open = append(n.open, strings.TrimPrefix(event, "Start"))
+		return nil
+	}
+
+	if !strings.HasPrefix(event, "End") {
+		return nil
+	}
+
+	what := strings.TrimPrefix(event, "End")
+	if innermost := n.open[len(n.open)-1]; innermost != what {
+		for _, o := range n.open {
+			if o == what {
+				return fmt.Errorf("%s before End%s", event, innermost)
+			}
+		}
+		return fmt.Errorf("%s without a matching Start%s", event, what)
+	}
+
+	n.open = n.open[:len(n.open)-1]
+	n.ended = what == "Document"
+	return nil
+}
+
+// replayJSONEvent makes the Processor (and, if not nil, ListProcessor,
+// CodeProcessor, QuoteProcessor or ImageProcessor) call represented by a given
+// event.
+func replayJSONEvent(event jsonEvent, processor Processor, lister ListProcessor, coder CodeProcessor,
+	quoter QuoteProcessor, imager ImageProcessor) error {
+	switch event.Event {
+	case "StartDocument":
+		processor.StartDocument()
+
+	case "EndDocument":
+		processor.EndDocument()
+
+	case "StartParagraph", "EndParagraph":
+		parType, ok := parseJSONParType(event.Type)
+		if !ok {
+			return fmt.Errorf("invalid paragraph type %q", event.Type)
+		}
+		if event.Event == "StartParagraph" {
+			processor.StartParagraph(parType)
+		} else {
+			processor.EndParagraph(parType)
+		}
+
+	case "Fragment":
+		if event.Text == nil {
+			return errors.New("missing text")
+		}
+		processor.Fragment(*event.Text)
+
+	case "SpecialToken":
+		for token, name := range jsonSpecialTokens {
+			if name == event.Token {
+				processor.SpecialToken(token)
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid special token %q", event.Token)
+
+	case "ChangeTextStyle":
+		if event.Style == nil {
+			return errors.New("missing style")
+		}
+		style := TextStyleRegular
+		for _, name := range *event.Style {
+			s, ok := parseJSONTextStyle(name)
+			if !ok {
+				return fmt.Errorf("invalid text style %q", name)
+			}
+			style |= s
+		}
+		processor.ChangeTextStyle(style)
+
+	case "StartLink":
+		if event.Target == nil {
+			return errors.New("missing target")
+		}
+		processor.StartLink(*event.Target)
+
+	case "EndLink":
+		processor.EndLink()
+
+	case "Code":
+		if event.Text == nil {
+			return errors.New("missing text")
+		}
+		if coder != nil {
+			coder.Code(*event.Text)
+		} else {
+			processor.Fragment(*event.Text)
+		}
+
+	case "CodeBlockInfo":
+		if event.Info == nil {
+			return errors.New("missing info")
+		}
+		if coder != nil {
+			coder.CodeBlockInfo(*event.Info)
+		}
+
+	case "Image":
+		if event.Source == nil {
+			return errors.New("missing source")
+		}
+		if event.Alt == nil {
+			return errors.New("missing alt")
+		}
+		if imager != nil {
+			imager.Image(*event.Source, *event.Alt)
+		} else if *event.Alt != "" {
+			processor.Fragment(*event.Alt)
+		}
+
+	case "StartList", "EndList", "StartListItem", "EndListItem":
+		list, err := parseJSONList(event.List)
+		if err != nil {
+			return err
+		}
+		if lister == nil {
+			return nil
+		}
+		switch event.Event {
+		case "StartList":
+			lister.StartList(list)
+		case "EndList":
+			lister.EndList(list)
+		case "StartListItem":
+			lister.StartListItem(list)
+		case "EndListItem":
+			lister.EndListItem(list)
+		}
+
+	case "StartQuote":
+		if quoter != nil {
+			quoter.StartQuote()
+		}
+
+	case "EndQuote":
+		if quoter != nil {
+			quoter.EndQuote()
+		}
+
+	default:
+		return fmt.Errorf("invalid event %q", event.Event)
+	}
+
+	return nil
+}
+
+// parseJSONParType converts the JSON representation of a paragraph type back
+// to a ParType. Returns false if the name is not valid.
+func parseJSONParType(name string) (ParType, bool) {
+	for parType, n := range jsonParTypes {
+		if n == name {
+			return parType, true
+		}
+	}
+	return ParTypeInvalid, false
+}
+
+// parseJSONTextStyle converts the JSON representation of a single text style
+// back to a TextStyle. Returns false if the name is not valid.
+func parseJSONTextStyle(name string) (TextStyle, bool) {
+	for style, n := range jsonTextStyles {
+		if n == name {
+			return style, true
+		}
+	}
+	return TextStyleRegular, false
+}
+
+// parseJSONList converts the JSON representation of a ListInfo back to a
+// ListInfo.
+func parseJSONList(list *jsonList) (ListInfo, error) {
+	if list == nil {
+		return ListInfo{}, errors.New("missing list")
+	}
+
+	for kind, name := range jsonListKinds {
+		if name == list.Kind {
+			return ListInfo{
+				Depth: list.Depth,
+				Kind:  kind,
+				Start: list.Start,
+				Tight: list.Tight,
+			}, nil
+		}
+	}
+
+	return ListInfo{}, fmt.Errorf("invalid list kind %q", list.Kind)
+}
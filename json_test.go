@@ -0,0 +1,139 @@
+package markydown
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// Tests serializing Processor calls to JSON.
+func TestJSONRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	Parse("# Hi\n\n1. ***<a>*** [b](c)\\\nd `e` ![h](i)\n\n```go\nf\n```\n> g", NewJSONRenderer(&buf))
+
+	expected := `{"event":"StartDocument"}
+{"event":"StartParagraph","type":"H1"}
+{"event":"Fragment","text":"Hi"}
+{"event":"EndParagraph","type":"H1"}
+{"event":"StartList","list":{"depth":1,"kind":"OL","start":1,"tight":true}}
+{"event":"StartListItem","list":{"depth":1,"kind":"OL","start":1,"tight":true}}
+{"event":"StartParagraph","type":"OL"}
+{"event":"ChangeTextStyle","style":["ST"]}
+{"event":"ChangeTextStyle","style":["ST","EM"]}
+{"event":"Fragment","text":"<a>"}
+{"event":"ChangeTextStyle","style":["EM"]}
+{"event":"ChangeTextStyle","style":[]}
+{"event":"SpecialToken","token":"SP"}
+{"event":"StartLink","target":"c"}
+{"event":"Fragment","text":"b"}
+{"event":"EndLink"}
+{"event":"SpecialToken","token":"NL"}
+{"event":"Fragment","text":"d"}
+{"event":"SpecialToken","token":"SP"}
+{"event":"Code","text":"e"}
+{"event":"SpecialToken","token":"SP"}
+{"event":"Image","source":"i","alt":"h"}
+{"event":"EndParagraph","type":"OL"}
+{"event":"EndListItem","list":{"depth":1,"kind":"OL","start":1,"tight":true}}
+{"event":"EndList","list":{"depth":1,"kind":"OL","start":1,"tight":true}}
+{"event":"CodeBlockInfo","info":"go"}
+{"event":"StartParagraph","type":"CODE"}
+{"event":"Fragment","text":"f"}
+{"event":"EndParagraph","type":"CODE"}
+{"event":"StartQuote"}
+{"event":"StartParagraph","type":"P"}
+{"event":"Fragment","text":"g"}
+{"event":"EndParagraph","type":"P"}
+{"event":"EndQuote"}
+{"event":"EndDocument"}
+`
+
+	assert.Equal(t, buf.String(), expected)
+}
+
+// Tests if replaying serialized calls generates the same calls as parsing the
+// document.
+func TestReplayJSON(t *testing.T) {
+	for _, input := range append(append([]string{}, readerTestInputs...), formatTestInputs...) {
+		var buf bytes.Buffer
+		Parse(input, NewJSONRenderer(&buf))
+
+		expected := &listProcessor{}
+		Parse(input, expected)
+
+		actual := &listProcessor{}
+		err := ReplayJSON(bytes.NewReader(buf.Bytes()), actual)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actual.res, expected.res)
+
+		// List events are skipped for Processors that don't want them
+		expectedNoLists := &testProcessor{}
+		Parse(input, expectedNoLists)
+
+		actualNoLists := &testProcessor{}
+		err = ReplayJSON(bytes.NewReader(buf.Bytes()), actualNoLists)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actualNoLists.res, expectedNoLists.res)
+
+		// Code spans are fragments for Processors that don't want them
+		expectedNoCode := &testProcessor{}
+		Parse(input, struct{ Processor }{expectedNoCode})
+
+		actualNoCode := &testProcessor{}
+		err = ReplayJSON(bytes.NewReader(buf.Bytes()), struct{ Processor }{actualNoCode})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actualNoCode.res, expectedNoCode.res)
+	}
+}
+
+// Tests replaying invalid serialized calls.
+func TestReplayJSONErrors(t *testing.T) {
+	const sd = `{"event":"StartDocument"} `
+
+	testData := map[string]string{
+		sd + `{"event":"Dance"}`:                                    `event 2: invalid event "Dance"`,
+		sd + `{"event":"StartParagraph","type":"H7"}`:               `event 2: invalid paragraph type "H7"`,
+		sd + `{"event":"Fragment"}`:                                 `event 2: missing text`,
+		sd + `{"event":"SpecialToken","token":"TAB"}`:               `event 2: invalid special token "TAB"`,
+		sd + `{"event":"ChangeTextStyle","style":["EM","UL"]}`:      `event 2: invalid text style "UL"`,
+		sd + `{"event":"ChangeTextStyle"}`:                          `event 2: missing style`,
+		sd + `{"event":"Code"}`:                                     `event 2: missing text`,
+		sd + `{"event":"CodeBlockInfo"}`:                            `event 2: missing info`,
+		sd + `{"event":"StartLink"}`:                                `event 2: missing target`,
+		sd + `{"event":"StartList"}`:                                `event 2: missing list`,
+		sd + `{"event":"StartList","list":{"depth":1,"kind":"DL"}}`: `event 2: invalid list kind "DL"`,
+		sd + "\n" + `{"event":"EndDocument"`:                        `event 2: unexpected EOF`,
+
+		// Events out of order
+		`{"event":"Fragment","text":"a"}`:                        `event 1: Fragment before StartDocument`,
+		sd + `{"event":"EndDocument"} {"event":"StartDocument"}`: `event 3: StartDocument after EndDocument`,
+		sd + `{"event":"EndDocument"} {"event":"EndQuote"}`:      `event 3: EndQuote after EndDocument`,
+		sd + sd: `event 2: StartDocument within a document`,
+		sd + `{"event":"EndList","list":{"depth":1,"kind":"UL"}}`:            `event 2: EndList without a matching StartList`,
+		sd + `{"event":"StartQuote"} {"event":"EndLink"}`:                    `event 3: EndLink without a matching StartLink`,
+		sd + `{"event":"StartParagraph","type":"P"} {"event":"EndDocument"}`: `event 3: EndDocument before EndParagraph`,
+	}
+
+	for input, expected := range testData {
+		p := &testProcessor{}
+		err := ReplayJSON(strings.NewReader(input), p)
+		assert.Equal(t, err != nil, true)
+		if err != nil {
+			assert.Equal(t, err.Error(), expected)
+		}
+	}
+
+	// Calls before the error are made
+	p := &testProcessor{}
+	ReplayJSON(strings.NewReader(`{"event":"StartDocument"} {"event":"Dance"}`), p)
+	assert.Equal(t, p.res, []string{"SD"})
+}
+
+// Tests if write errors are reported.
+func TestJSONRendererError(t *testing.T) {
+	r := NewJSONRenderer(failingWriter{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
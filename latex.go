@@ -0,0 +1,432 @@
+package markydown
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LaTeXRenderer is a Processor that renders a Markydown document as LaTeX,
+// writing the results to an io.Writer.
+//
+// Headings of levels 1 to 3 become `\section`s, `\subsection`s and
+// `\subsubsection`s (deeper ones become `\paragraph`s and `\subparagraph`s),
+// lists become `itemize` and `enumerate` environments, block quotes become
+// `quote` environments, emphasis and strong emphasis become `\emph` and
+// `\textbf`, code spans become `\texttt`, code blocks become `verbatim`
+// environments (or, if they contain `\end{verbatim}`, which would end the
+// environment early, `flushleft` environments with escaped typewriter text),
+// links become `\href` (from the hyperref package), images become
+// `\includegraphics` (from the graphicx package), hard line breaks become `\\`
+// and escaped spaces become `~`. Characters with special meanings in LaTeX are
+// escaped, both in text and in link targets.
+type LaTeXRenderer struct {
+	// FullDocument tells whether the output shall be a full LaTeX document
+	// (that is, with a preamble and wrapped in a `document` environment). If
+	// false, only the document contents are generated, to be included in
+	// another document (which must use the hyperref and graphicx packages).
+	FullDocument bool
+
+	w          io.Writer   // Where the output goes to
+	err        error       // The first error found while writing, if any
+	started    bool        // Have we written any paragraph or list yet?
+	parType    ParType     // Type of the current paragraph
+	textStyle  TextStyle   // The current text style
+	openStyles []TextStyle // Styles whose commands are open, in the order they were opened
+	links      []string    // Stack of targets of the links we are in
+	lists      []ListInfo  // Stack of lists we are in
+	code       string      // Contents of the current code block
+	blank      bool        // Is there no text yet in the current paragraph (or list item)?
+}
+
+// NewLaTeXRenderer creates a new LaTeXRenderer that writes its output to w. By
+// default, it generates just the document contents; set FullDocument to true
+// to get a full LaTeX document.
+func NewLaTeXRenderer(w io.Writer) *LaTeXRenderer {
+	return &LaTeXRenderer{
+		w: w,
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *LaTeXRenderer) Err() error {
+	return r.err
+}
+
+// StartDocument implements the Processor interface.
+func (r *LaTeXRenderer) StartDocument() {
+	r.started = false
+	r.textStyle = TextStyleRegular
+	r.openStyles = nil
+	r.links = nil
+	r.lists = nil
+
+	if r.FullDocument {
+		r.write("\\documentclass{article}\n\\usepackage{hyperref}\n\\usepackage{graphicx}\n\\begin{document}\n\n")
+	}
+}
+
+// EndDocument implements the Processor interface.
+func (r *LaTeXRenderer) EndDocument() {
+	if r.FullDocument {
+		r.write("\n\\end{document}\n")
+	}
+}
+
+// StartParagraph implements the Processor interface.
+func (r *LaTeXRenderer) StartParagraph(parType ParType) {
+	r.parType = parType
+	r.blank = true
+
+	if isListParType(parType) {
+		r.write("\\item ")
+		return
+	}
+
+	r.startBlock()
+
+	if parType == ParTypeCodeBlock {
+		r.code = ""
+		return
+	}
+
+	if level := parType.HeadingLevel(); level > 0 {
+		r.write("\\" + latexHeadingCommand(level) + "{")
+	}
+}
+
+// EndParagraph implements the Processor interface.
+func (r *LaTeXRenderer) EndParagraph(parType ParType) {
+	r.closeStylesFor(TextStyleRegular)
+
+	if parType == ParTypeCodeBlock {
+		r.writeCodeBlock()
+		return
+	}
+
+	if parType.HeadingLevel() > 0 {
+		r.write("}")
+	}
+	r.write("\n")
+}
+
+// Fragment implements the Processor interface.
+func (r *LaTeXRenderer) Fragment(text string) {
+	if r.parType == ParTypeCodeBlock {
+		r.code += text
+		return
+	}
+
+	r.openStylesFor(r.textStyle)
+	r.write(escapeLaTeX(text))
+	r.blank = false
+}
+
+// SpecialToken implements the Processor interface.
+func (r *LaTeXRenderer) SpecialToken(token SpecialToken) {
+	r.openStylesFor(r.textStyle)
+
+	switch token {
+	case SpecialTokenSpace:
+		r.write(" ")
+	case SpecialTokenNonBreakingSpace:
+		r.write("~")
+		r.blank = false
+	case SpecialTokenLineBreak:
+		if r.blank {
+			// There must be a line for `\\` to end
+			r.write("\\mbox{}")
+			r.blank = false
+		}
+		if r.parType.HeadingLevel() > 0 {
+			// Headings are "moving arguments", where `\\` is fragile
+			r.write("\\protect\\\\ ")
+		} else {
+			r.write("\\\\\n")
+		}
+	}
+}
+
+// ChangeTextStyle implements the Processor interface.
+//
+// Just like in the HTMLRenderer, the commands for the new styles are opened
+// only when some content is written.
+func (r *LaTeXRenderer) ChangeTextStyle(style TextStyle) {
+	r.closeStylesFor(style)
+	r.textStyle = style
+}
+
+// StartLink implements the Processor interface.
+//
+// Styles are closed before and reopened within the link text, so that the
+// braces are properly nested.
+func (r *LaTeXRenderer) StartLink(target string) {
+	r.closeStylesFor(TextStyleRegular)
+	r.links = append(r.links, target)
+	r.write("\\href{" + escapeLaTeXURL(target) + "}{")
+}
+
+// EndLink implements the Processor interface.
+func (r *LaTeXRenderer) EndLink() {
+	if len(r.links) == 0 {
+		return
+	}
+
+	r.closeStylesFor(TextStyleRegular)
+	r.links = r.links[:len(r.links)-1]
+	r.write("}")
+}
+
+// Code implements the CodeProcessor interface.
+func (r *LaTeXRenderer) Code(text string) {
+	r.openStylesFor(r.textStyle)
+	r.write("\\texttt{" + escapeLaTeX(text) + "}")
+	r.blank = false
+}
+
+// CodeBlockInfo implements the CodeProcessor interface. LaTeX doesn't care
+// about the info string.
+func (r *LaTeXRenderer) CodeBlockInfo(info string) {
+}
+
+// StartList implements the ListProcessor interface.
+func (r *LaTeXRenderer) StartList(list ListInfo) {
+	if len(r.lists) == 0 {
+		r.startBlock()
+	}
+
+	r.lists = append(r.lists, list)
+
+	if list.Kind == ListKindBulleted {
+		r.write("\\begin{itemize}\n")
+		return
+	}
+
+	r.write("\\begin{enumerate}\n")
+
+	if list.Start != 1 {
+		// LaTeX has one counter for each level of nested enumerations
+		depth := 0
+		for _, l := range r.lists {
+			if l.Kind == ListKindOrdered {
+				depth++
+			}
+		}
+		counter := "enum" + strings.Repeat("i", depth)
+		if depth == 4 {
+			counter = "enumiv"
+		}
+		r.write("\\setcounter{" + counter + "}{" + strconv.Itoa(list.Start-1) + "}\n")
+	}
+}
+
+// EndList implements the ListProcessor interface.
+func (r *LaTeXRenderer) EndList(list ListInfo) {
+	if len(r.lists) > 0 {
+		r.lists = r.lists[:len(r.lists)-1]
+	}
+
+	if list.Kind == ListKindBulleted {
+		r.write("\\end{itemize}\n")
+	} else {
+		r.write("\\end{enumerate}\n")
+	}
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *LaTeXRenderer) StartListItem(list ListInfo) {
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *LaTeXRenderer) EndListItem(list ListInfo) {
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *LaTeXRenderer) StartQuote() {
+	r.startBlock()
+	r.write("\\begin{quote}\n")
+	r.started = false // No blank line before the first paragraph
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *LaTeXRenderer) EndQuote() {
+	r.write("\\end{quote}\n")
+	r.started = true
+}
+
+// Image implements the ImageProcessor interface. LaTeX has no use for the
+// alternative text. The source is escaped like link targets, which is fine for
+// usual file names (LaTeX doesn't cope well with weird ones anyway).
+func (r *LaTeXRenderer) Image(source, alt string) {
+	r.openStylesFor(r.textStyle)
+	r.write("\\includegraphics{" + escapeLaTeXURL(source) + "}")
+	r.blank = false
+}
+
+// writeCodeBlock writes the current code block. Its contents are written as
+// is in a verbatim environment, unless they contain `\end{verbatim}`, which
+// would end the environment right there. In this case, the lines are escaped
+// and written in a typewriter font instead.
+func (r *LaTeXRenderer) writeCodeBlock() {
+	if !strings.Contains(r.code, "\\end{verbatim}") {
+		r.write("\\begin{verbatim}\n")
+		if r.code != "" {
+			r.write(r.code + "\n")
+		}
+		r.write("\\end{verbatim}\n")
+		return
+	}
+
+	r.write("\\begin{flushleft}\\ttfamily\n")
+	for i, line := range strings.Split(r.code, "\n") {
+		if i > 0 {
+			r.write("\\\\\n")
+		}
+		// `\mbox{}` keeps `\\` from complaining about empty lines
+		r.write("\\mbox{}" + latexCodeSpaces.Replace(escapeLaTeX(line)))
+	}
+	r.write("\n\\end{flushleft}\n")
+}
+
+// startBlock starts a new top-level block (like a paragraph or a list),
+// separating it from the previous one with a blank line.
+func (r *LaTeXRenderer) startBlock() {
+	if r.started {
+		r.write("\n")
+	}
+	r.started = true
+}
+
+// closeStylesFor closes the commands of the open styles that are not part of
+// style. Commands opened after them are closed too (to keep the braces
+// properly nested), and will be reopened by the next openStylesFor.
+func (r *LaTeXRenderer) closeStylesFor(style TextStyle) {
+	keep := 0
+	for keep < len(r.openStyles) && style.Has(r.openStyles[keep]) {
+		keep++
+	}
+
+	r.write(strings.Repeat("}", len(r.openStyles)-keep))
+	r.openStyles = r.openStyles[:keep]
+}
+
+// openStylesFor opens the commands of the styles in style that are not open
+// yet.
+func (r *LaTeXRenderer) openStylesFor(style TextStyle) {
+	for _, s := range textStyles {
+		if style.Has(s) && !r.isStyleOpen(s) {
+			r.write(latexStyleCommand(s))
+			r.openStyles = append(r.openStyles, s)
+		}
+	}
+}
+
+// isStyleOpen checks if the command of a given style is open.
+func (r *LaTeXRenderer) isStyleOpen(style TextStyle) bool {
+	for _, s := range r.openStyles {
+		if s == style {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes s to the output, unless a previous write failed.
+func (r *LaTeXRenderer) write(s string) {
+	if r.err != nil || len(s) == 0 {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
+
+// latexHeadingCommand returns the name of the LaTeX command used for a heading
+// of a given level.
+func latexHeadingCommand(level int) string {
+	switch level {
+	case 1:
+		return "section"
+	case 2:
+		return "subsection"
+	case 3:
+		return "subsubsection"
+	case 4:
+		return "paragraph"
+	default:
+		return "subparagraph"
+	}
+}
+
+// latexStyleCommand returns the LaTeX command (including the opening brace)
+// used to start text in a given (single, not combined) style.
+func latexStyleCommand(style TextStyle) string {
+	switch style {
+	case TextStyleEmphasis:
+		return "\\emph{"
+	case TextStyleStrong:
+		return "\\textbf{"
+	default:
+		return "{"
+	}
+}
+
+// latexEscapes maps the characters that must be escaped in LaTeX text to their
+// escaped versions. (Square brackets are not special in text, but could be
+// taken as optional arguments after `\\` or `\item`.)
+var latexEscapes = map[rune]string{
+	'\\': "\\textbackslash{}",
+	'{':  "\\{",
+	'}':  "\\}",
+	'$':  "\\$",
+	'&':  "\\&",
+	'#':  "\\#",
+	'_':  "\\_",
+	'%':  "\\%",
+	'~':  "\\textasciitilde{}",
+	'^':  "\\textasciicircum{}",
+	'<':  "\\textless{}",
+	'>':  "\\textgreater{}",
+	'|':  "\\textbar{}",
+	'`':  "\\textasciigrave{}",
+	'[':  "{[}",
+	']':  "{]}",
+}
+
+// latexCodeSpaces replaces the spaces and tabs of escaped code lines with
+// non-breaking spaces, which LaTeX doesn't collapse.
+var latexCodeSpaces = strings.NewReplacer(" ", "~", "\t", "~~~~")
+
+// escapeLaTeX escapes a text to be used in LaTeX.
+func escapeLaTeX(text string) string {
+	var b strings.Builder
+	for _, c := range text {
+		if escaped, ok := latexEscapes[c]; ok {
+			b.WriteString(escaped)
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// escapeLaTeXURL escapes a URL to be used as the target of an `\href`.
+// Characters that would upset LaTeX are either escaped with a backslash
+// (which hyperref removes) or percent-encoded. The `%` of percent-encoded
+// characters is escaped like any other, as it would otherwise start a comment
+// when the `\href` is within the argument of another command.
+func escapeLaTeXURL(url string) string {
+	var b strings.Builder
+	for _, c := range url {
+		switch {
+		case c == '#' || c == '%' || c == '&':
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		case c <= ' ' || c == 0x7f || strings.ContainsRune("\\{}^~", c):
+			fmt.Fprintf(&b, "\\%%%02X", c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package markydown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// renderLaTeX renders a Markydown document as LaTeX.
+func renderLaTeX(input string) string {
+	var buf bytes.Buffer
+	Parse(input, NewLaTeXRenderer(&buf))
+	return buf.String()
+}
+
+// Tests rendering LaTeX.
+func TestLaTeXRenderer(t *testing.T) {
+	testData := map[string]string{
+		"": "",
+
+		// Headings and paragraphs
+		"# One\n\n## Two\n\n### Three\n\n#### Four\n\nText.": "\\section{One}\n\n\\subsection{Two}\n\n" +
+			"\\subsubsection{Three}\n\n\\paragraph{Four}\n\nText.\n",
+
+		// Escapes
+		"$1 & 50% of #2 {x_y} ~^ \\\\ <|> [a]": "\\$1 \\& 50\\% of \\#2 \\{x\\_y\\} \\textasciitilde{}\\textasciicircum{} " +
+			"\\textbackslash{} \\textless{}\\textbar{}\\textgreater{} {[}a{]}\n",
+		"100\\ kg":                "100~kg\n",
+		"Use `a_b`, ``\\`c`` \\`": "Use \\texttt{a\\_b}, \\texttt{\\textbackslash{}\\textasciigrave{}c} \\textasciigrave{}\n",
+
+		// Styles and links
+		"*a **b* c** d":          "\\emph{a \\textbf{b}}\\textbf{ c} d\n",
+		"*See [this](x)*":        "\\emph{See }\\href{x}{\\emph{this}}\n",
+		"[A](http://x.com/#a%b)": "\\href{http://x.com/\\#a\\%b}{A}\n",
+		"[A](a\\\\b{c}~ d)":      "\\href{a\\%5Cb\\%7Bc\\%7D\\%7E\\%20d}{A}\n",
+		"# See [A](a b)":         "\\section{See \\href{a\\%20b}{A}}\n",
+		"*See ![cat](c_1.png)*":  "\\emph{See \\includegraphics{c_1.png}}\n",
+
+		// Line breaks
+		"One\\\ntwo":   "One\\\\\ntwo\n",
+		"# One\\\ntwo": "\\section{One\\protect\\\\ two}\n",
+		"\\\nfoo":      "\\mbox{}\\\\\nfoo\n",
+		"+ \\\nfoo":    "\\begin{itemize}\n\\item \\mbox{}\\\\\nfoo\n\\end{itemize}\n",
+		"# \\\nfoo":    "\\section{\\mbox{}\\protect\\\\ foo}\n",
+
+		// Code blocks
+		"Text\n\n```go\n\\emph{x}\n```": "Text\n\n\\begin{verbatim}\n\\emph{x}\n\\end{verbatim}\n",
+		"```\n```":                      "\\begin{verbatim}\n\\end{verbatim}\n",
+
+		// Code blocks that would end a verbatim environment early
+		"```\nif  x\n\n\\end{verbatim}\\input{evil}\n```": "\\begin{flushleft}\\ttfamily\n\\mbox{}if~~x\\\\\n\\mbox{}\\\\\n" +
+			"\\mbox{}\\textbackslash{}end\\{verbatim\\}\\textbackslash{}input\\{evil\\}\n\\end{flushleft}\n",
+
+		// Lists
+		"Text\n\n+ One\n+ Two\n\nText": "Text\n\n\\begin{itemize}\n\\item One\n\\item Two\n\\end{itemize}\n\nText\n",
+		"Text\n\n> + One\n>\n> > Two\n\nText": "Text\n\n\\begin{quote}\n\\begin{itemize}\n\\item One\n\\end{itemize}\n\n" +
+			"\\begin{quote}\nTwo\n\\end{quote}\n\\end{quote}\n\nText\n",
+		"+ One\n\n    3. Three\n\n        3. Three": "\\begin{itemize}\n\\item One\n\\begin{enumerate}\n" +
+			"\\setcounter{enumi}{2}\n\\item Three\n\\begin{enumerate}\n\\setcounter{enumii}{2}\n\\item Three\n" +
+			"\\end{enumerate}\n\\end{enumerate}\n\\end{itemize}\n",
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, renderLaTeX(input), expected)
+	}
+}
+
+// Tests rendering full LaTeX documents.
+func TestLaTeXRendererFullDocument(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewLaTeXRenderer(&buf)
+	r.FullDocument = true
+	Parse("Hi", r)
+
+	assert.Equal(t, buf.String(), "\\documentclass{article}\n\\usepackage{hyperref}\n"+
+		"\\usepackage{graphicx}\n\\begin{document}\n\nHi\n\n\\end{document}\n")
+}
+
+// Tests if write errors are reported.
+func TestLaTeXRendererError(t *testing.T) {
+	r := NewLaTeXRenderer(failingWriter{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
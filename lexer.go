@@ -10,8 +10,9 @@ import "unicode/utf8"
 // expect in a real lexer. (Particularly when handling links; we do quite a bit
 // of work here to simplify the work on the parser.)
 //
-// Apart from the links case mentioned above, this function doesn't know
-// much the Markydown syntax.
+// Apart from the links case mentioned above (and code spans and images, which
+// are lexed as single tokens), this function doesn't know much the Markydown
+// syntax.
 //
 // This function handles escaped characters and handles line breaks smartly (to
 // deal with all that CRLF x CR x whatever mess).
@@ -26,6 +27,7 @@ func (p *parser) nextRune() (runeType, bool) {
 	}
 
 	// Decode next rune
+	start := p.input
 	r, w := utf8.DecodeRuneInString(p.input)
 	p.input = p.input[w:]
 
@@ -46,11 +48,30 @@ func (p *parser) nextRune() (runeType, bool) {
 		return runeTypeEmphasis, false
 
 	case isLinkStart(r):
-		if p.lookAheadForLink() {
+		// Links don't nest: within the text of a link, `[` is just text
+		if len(p.linkTarget) == 0 && p.lookAheadForLink() {
 			return runeTypeLinkStart, false
 		}
 		return runeTypeText, false
 
+	case isImageStart(r):
+		if p.lookAheadForImage() {
+			return runeTypeImage, false
+		}
+		return runeTypeText, false
+
+	case isCodeDelimiter(r):
+		text, length, fence := p.codeSpan(start)
+		if length == 0 {
+			p.diagnose(SeverityWarning, p.offsetOf(start), p.offsetOf(start)+fence, CodeUnclosedCodeSpan,
+				"code span is never closed; parsing its backticks as regular text")
+			p.input = start[fence:]
+			return runeTypeText, false
+		}
+		p.codeText = text
+		p.input = start[length:]
+		return runeTypeCode, false
+
 	case isLinkEnd(r):
 		if len(p.linkTarget) > 0 {
 			return runeTypeLinkEnd, false
@@ -68,6 +89,9 @@ func (p *parser) nextRune() (runeType, bool) {
 			}
 			return runeTypeNewLine, true
 		}
+		if isHorizontalSpace(r) {
+			return runeTypeNonBreakingSpace, true
+		}
 		return runeTypeText, true
 
 	case isNewLine(r):
@@ -4,28 +4,61 @@ import "unicode/utf8"
 
 // lookAheadForLink first looks ahead to detect if the `[` we just found is
 // really a link. Then, if we are indeed parsing a link, it looks ahead a bit
-// further to obtain the link target.
+// further to obtain the link target. Links cannot span multiple paragraphs, so
+// the look ahead never goes past the end of the current paragraph.
 //
 // Returns true if we are parsing a link, false otherwise.
 func (p *parser) lookAheadForLink() bool {
-	// We are only looking ahead, use a copy and leave the real input untouched
-	input := p.input
+	// We are only looking ahead, so the real input is left untouched
+	linkStart := p.offset() - 1 // The `[` was already consumed
+
+	textLen, ok := p.linkTextLength(p.input)
+	if !ok {
+		return false
+	}
+
+	p.linkTextLen = textLen
+	return p.parseLinkTarget(p.input[textLen+1:], linkStart) // `+1` skips the `]`
+}
+
+// linkTextLength returns the length in bytes of the text of the link (or
+// image) whose `[` is right before input, up to (but excluding) its `]`.
+// Returns false if there is no `]` before the end of the paragraph.
+func (p *parser) linkTextLength(input string) (int, bool) {
+	text := input
 
 	for {
 		r, w := utf8.DecodeRuneInString(input)
 
 		switch {
-		case len(input) == 0:
-			return false
+		case len(input) == 0 || p.isParagraphEnd(input):
+			return 0, false
 
 		case isLinkEnd(r):
-			input = input[w:]
-			return p.parseLinkTarget(input)
+			return len(text) - len(input), true
 
 		case isEscape(r):
 			input = input[w:]
-			_, w = utf8.DecodeRuneInString(input)
-			input = input[w:]
+			r, w = utf8.DecodeRuneInString(input)
+			if !isNewLine(r) {
+				input = input[w:]
+			}
+
+		case isCodeDelimiter(r):
+			// A `]` within a code span doesn't end the link
+			_, length, fence := p.codeSpan(input)
+			if length == 0 {
+				length = fence
+			}
+			input = input[length:]
+
+		case isImageStart(r):
+			// Neither does the `]` of an image, as in `[![Badge](b.png)](x)`
+			length := p.imageLength(input)
+			if length == 0 {
+				length = w
+			}
+			input = input[length:]
 
 		default:
 			input = input[w:]
@@ -33,12 +66,16 @@ func (p *parser) lookAheadForLink() bool {
 	}
 }
 
-// parseLinkTarget parses a link target from a given input string. Returns a
-// Boolean indicating if a link target was actually found on input.
-func (p *parser) parseLinkTarget(input string) bool {
+// parseLinkTarget parses a link target from a given input string, which is
+// part of a link starting at offset linkStart. Returns a Boolean indicating if
+// a link target was actually found on input.
+func (p *parser) parseLinkTarget(input string, linkStart int) bool {
+	linkEnd := p.offsetOf(input) - 1 // Where the `]` is
 	r, w := utf8.DecodeRuneInString(input)
 
 	if !isLinkTargetStart(r) {
+		p.diagnoseLink(linkStart, linkEnd, linkEnd+1, CodeNotALink,
+			"`[` and `]` not followed by a link target; parsing them as regular text")
 		return false
 	}
 
@@ -52,22 +89,30 @@ func (p *parser) parseLinkTarget(input string) bool {
 		r, w = utf8.DecodeRuneInString(input)
 
 		switch {
-		case len(input) == 0:
+		case len(input) == 0 || p.isParagraphEnd(input):
+			p.diagnoseLink(linkStart, linkEnd, p.offsetOf(input), CodeUnclosedLink,
+				"link target is never closed with `)`; parsing it as regular text")
 			return false
 
 		case isLinkTargetEnd(r):
+			if targetEnd == 0 {
+				p.diagnoseLink(linkStart, linkEnd, p.offsetOf(input)+w, CodeEmptyLinkTarget,
+					"link target is empty; parsing it as regular text")
+				return false
+			}
 			p.linkTarget = target[:targetEnd]
 			p.linkTargetLen = targetLen
 			return true
 
 		case isEscape(r):
+			// The escape is dropped from the target, the escaped rune is kept
 			input = input[w:]
-			target = target[:targetEnd] + target[targetEnd+1:]
-			targetEnd += w
+			target = target[:targetEnd] + target[targetEnd+w:]
 			targetLen += w
 
-			r, w = utf8.DecodeRuneInString(input)
+			_, w = utf8.DecodeRuneInString(input)
 			input = input[w:]
+			targetEnd += w
 			targetLen += w
 
 		default:
@@ -78,12 +123,32 @@ func (p *parser) parseLinkTarget(input string) bool {
 	}
 }
 
+// diagnoseLink reports a problem with something that looked like a link, but
+// was not. It starts at offset start, has its `]` at offset linkEnd and ends at
+// offset end.
+//
+// When `[`s are nested, all of them find the same `]`, so the problem is
+// reported only for the first (outermost) one.
+func (p *parser) diagnoseLink(start, linkEnd, end int, code DiagnosticCode, message string) {
+	if linkEnd == p.badLinkEnd {
+		return
+	}
+
+	p.badLinkEnd = linkEnd
+
+	severity := SeverityError
+	if code == CodeNotALink {
+		severity = SeverityWarning
+	}
+
+	p.diagnose(severity, start, end, code, message)
+}
+
 // consumeLinkTarget chomps the link target that is expected to be right on the
 // start of the input.
 func (p *parser) consumeLinkTarget() {
 	p.input = p.input[p.linkTargetLen+2:] // `+2` accounts for the parens themselves
-	p.frag = p.input
-	p.fragEnd = 0
+	p.startFragment()
 	p.linkTarget = ""
 	p.linkTargetLen = 0
 }
@@ -0,0 +1,320 @@
+package markydown
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ListProcessor is a Processor that wants to know about the structure of lists.
+//
+// Every Processor is told about list items through calls to StartParagraph and
+// EndParagraph with ParTypeBulletedList or ParTypeOrderedList, which is enough
+// for simple lists. If the Processor passed to the parser implements this
+// interface, these calls are additionally wrapped in calls telling where lists
+// and list items start and end. This makes it possible to handle nested lists:
+// a nested list is reported after the paragraph of the item it is nested in,
+// but before that item ends. For example, this Markydown:
+//
+//	Numbers:
+//
+//	+ One
+//
+//	    + One and a half
+//
+//	+ Two
+//
+// generates this sequence of calls (paragraph contents omitted):
+//
+//	StartParagraph(ParTypeText)
+//	EndParagraph(ParTypeText)
+//	StartList(depth 1)
+//	StartListItem(depth 1)
+//	StartParagraph(ParTypeBulletedList)
+//	EndParagraph(ParTypeBulletedList)
+//	StartList(depth 2)
+//	StartListItem(depth 2)
+//	StartParagraph(ParTypeBulletedList)
+//	EndParagraph(ParTypeBulletedList)
+//	EndListItem(depth 2)
+//	EndList(depth 2)
+//	EndListItem(depth 1)
+//	StartListItem(depth 1)
+//	StartParagraph(ParTypeBulletedList)
+//	EndParagraph(ParTypeBulletedList)
+//	EndListItem(depth 1)
+//	EndList(depth 1)
+type ListProcessor interface {
+	Processor
+
+	// StartList is called when a list starts.
+	StartList(list ListInfo)
+
+	// EndList is called when a list ends.
+	EndList(list ListInfo)
+
+	// StartListItem is called when an item of a list starts.
+	StartListItem(list ListInfo)
+
+	// EndListItem is called when an item of a list ends.
+	EndListItem(list ListInfo)
+}
+
+// ListInfo describes a list.
+type ListInfo struct {
+	// Depth is the list nesting depth. Top-level lists have depth 1, lists
+	// nested within them have depth 2, and so on.
+	Depth int
+
+	// Kind is the kind of list.
+	Kind ListKind
+
+	// Start is the number of the first item of an ordered list. (The numbers
+	// of the other items are ignored; they are always numbered sequentially.)
+	// Always zero for bulleted lists.
+	Start int
+
+	// Tight tells if this is a tight list, i.e., a list whose items are not
+	// separated by blank lines. (Renderers may want to use less spacing
+	// between the items of tight lists.) A blank line between any two items
+	// makes the list loose. Lists with a single item are tight.
+	Tight bool
+}
+
+// ListKind is a kind of list.
+type ListKind int
+
+const (
+	// ListKindBulleted is a bulleted list, with items marked with `+`.
+	ListKindBulleted ListKind = iota
+
+	// ListKindOrdered is an ordered list, with items marked with numbers
+	// followed by a period, like `1.`.
+	ListKindOrdered
+)
+
+// maxListNumberDigits is the maximum number of digits in the number of an
+// ordered list item. (This is what CommonMark allows, and avoids overflows.)
+const maxListNumberDigits = 9
+
+// listNestingIndent is how much further (in columns) than the items of a list
+// the bullet of an item must be indented to start a nested list.
+const listNestingIndent = 4
+
+// openList is a list the parser is currently in.
+type openList struct {
+	indent int      // The indentation of the list's first item
+	info   ListInfo // Information about the list
+}
+
+// isListParType checks if a given paragraph type is a list item.
+func isListParType(parType ParType) bool {
+	return parType == ParTypeBulletedList || parType == ParTypeOrderedList
+}
+
+// listMarker checks if a given input starts with a list item marker (either a
+// bullet or a number followed by a period) followed by a space. If so, returns
+// the kind of list, the item number (for ordered lists) and the length of the
+// marker in bytes. Otherwise, the returned length is zero.
+func listMarker(input string) (kind ListKind, number int, length int) {
+	r, w := utf8.DecodeRuneInString(input)
+
+	if isBullet(r) {
+		kind, length = ListKindBulleted, w
+	} else {
+		digits := len(input) - len(strings.TrimLeftFunc(input, isDigit))
+		r, w = utf8.DecodeRuneInString(input[digits:])
+		if digits == 0 || digits > maxListNumberDigits || !isOrderedListDelimiter(r) {
+			return ListKindBulleted, 0, 0
+		}
+		number, _ = strconv.Atoi(input[:digits])
+		kind, length = ListKindOrdered, digits+w
+	}
+
+	if r, _ = utf8.DecodeRuneInString(input[length:]); !isHorizontalSpace(r) {
+		return ListKindBulleted, 0, 0
+	}
+
+	return kind, number, length
+}
+
+// enterListItem updates the stack of open lists to account for a new list item
+// with a given indentation, kind and number, and tells the processor about any
+// lists that are ending and starting.
+//
+// An item belongs to the same list as the previous one unless it is indented
+// at least listNestingIndent columns further (in which case it starts a nested
+// list), is indented less than the first item of a nested list (in which case
+// the nested list ends) or is of a different kind (in which case the current
+// list ends and a new one starts).
+func (p *parser) enterListItem(indent int, kind ListKind, number int) {
+	for len(p.lists) > 1 && indent < p.lists[len(p.lists)-1].indent {
+		p.closeLists(len(p.lists) - 1)
+	}
+
+	if len(p.lists) > 0 && p.lists[len(p.lists)-1].info.Kind != kind &&
+		indent < p.lists[len(p.lists)-1].indent+listNestingIndent {
+		p.closeLists(len(p.lists) - 1)
+	}
+
+	switch {
+	case len(p.lists) == 0 || indent >= p.lists[len(p.lists)-1].indent+listNestingIndent:
+		p.lists = append(p.lists, openList{
+			indent: indent,
+			info: ListInfo{
+				Depth: len(p.lists) + 1,
+				Kind:  kind,
+				Start: number,
+				Tight: !p.isLooseList(indent, kind, len(p.lists) > 0),
+			},
+		})
+		if p.lister != nil {
+			p.at(p.parStart, p.parStart)
+			p.lister.StartList(p.lists[len(p.lists)-1].info)
+		}
+
+	case p.lister != nil:
+		p.at(p.lastEnd, p.lastEnd)
+		p.lister.EndListItem(p.lists[len(p.lists)-1].info)
+	}
+
+	if p.lister != nil {
+		p.at(p.parStart, p.parStart)
+		p.lister.StartListItem(p.lists[len(p.lists)-1].info)
+	}
+}
+
+// isListItemAhead checks if we are in a list item and the input starts with
+// another list item.
+func (p *parser) isListItemAhead() bool {
+	if !p.inListItem {
+		return false
+	}
+
+	_, _, markerLen := listMarker(p.input)
+	return markerLen > 0
+}
+
+// isLooseList looks ahead in the input to check if the list whose first item
+// starts at the current input position is loose. The list's indentation and
+// kind are passed as parameters, as well as a flag telling if a shallower
+// indentation ends the list (as is the case for nested lists).
+//
+// This looks ahead up to the end of the list, or up to the first blank line
+// between its items. Everything looked ahead stays in the input buffer, so,
+// when reading from an io.Reader, memory use is O(size of a tight list).
+func (p *parser) isLooseList(indent int, kind ListKind, isNested bool) bool {
+	first, pos, _ := p.lookAheadLine(0) // skip the line with the first item
+	prevBlank := false
+	prevEscaped := endsWithEscapedNewLine(first)
+
+	for {
+		line, next, ok := p.lookAheadLine(pos)
+		if !ok {
+			return false
+		}
+		pos = next
+
+		escaped := prevEscaped
+		prevEscaped = endsWithEscapedNewLine(line)
+
+		if isBlankLine(line) {
+			prevBlank = true
+			continue
+		}
+
+		if escaped {
+			prevBlank = false // continuation line after a hard line break
+			continue
+		}
+
+		lineIndent, contents := indentationOf(line)
+		lineKind, _, markerLen := listMarker(contents)
+
+		switch {
+		case markerLen == 0 && prevBlank:
+			return false // a new paragraph ends the list
+
+		case markerLen == 0 || lineIndent >= indent+listNestingIndent:
+			prevBlank = false // continuation line or nested item
+
+		case lineKind != kind || isNested && lineIndent < indent:
+			return false // an item not in this list
+
+		case prevBlank:
+			return true // an item of this list after a blank line
+
+		default:
+			prevBlank = false // an item of this list right after another
+		}
+	}
+}
+
+// endsWithEscapedNewLine checks if a given line (without its new line) ends
+// with a backslash escaping the new line, i.e., with a hard line break.
+func endsWithEscapedNewLine(line string) bool {
+	backslashes := len(line) - len(strings.TrimRightFunc(line, isEscape))
+	return backslashes%2 == 1
+}
+
+// lookAheadLine returns the input line starting at a given position in the
+// input (without its new line) and the position where the next line starts.
+// More input is read as needed. Returns false if there is no line at the
+// given position.
+func (p *parser) lookAheadLine(pos int) (line string, next int, ok bool) {
+	for {
+		rest := p.input[pos:]
+		i := strings.IndexAny(rest, "\n\r")
+
+		if i < 0 && p.fill() {
+			continue
+		}
+
+		if len(rest) == 0 {
+			return "", pos, false
+		}
+
+		if i < 0 {
+			return rest, len(p.input), true
+		}
+
+		r := rune(rest[i])
+		return rest[:i], len(p.input) - len(skipNewLine(rest[i+1:], r)), true
+	}
+}
+
+// indentationOf returns the indentation of a given line (in columns; tabs move
+// to the next multiple of four columns) and the line contents after the
+// indentation.
+func indentationOf(line string) (int, string) {
+	indent := 0
+
+	for i, r := range line {
+		switch {
+		case r == '\t':
+			indent += 4 - indent%4
+		case isHorizontalSpace(r):
+			indent++
+		default:
+			return indent, line[i:]
+		}
+	}
+
+	return indent, ""
+}
+
+// closeLists closes open lists (and their current items) until only n lists are
+// left open.
+func (p *parser) closeLists(n int) {
+	for len(p.lists) > n {
+		info := p.lists[len(p.lists)-1].info
+		p.lists = p.lists[:len(p.lists)-1]
+
+		if p.lister != nil {
+			p.at(p.lastEnd, p.lastEnd)
+			p.lister.EndListItem(info)
+			p.at(p.lastEnd, p.lastEnd)
+			p.lister.EndList(info)
+		}
+	}
+}
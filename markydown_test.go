@@ -8,7 +8,7 @@ import (
 
 // Example shows how to use the parser to create a simple Markydown-to-HTML
 // converter. This gives an idea on how the parser works, but don't expect
-// this coverter to be foolproof -- it is not!
+// this coverter to be foolproof -- it is not! (Use HTMLRenderer for real work.)
 func Example() {
 	doc := `
 	# The title
@@ -41,7 +41,7 @@ func Example() {
 	// <li><a href="http://www.stackedboxes.com">Linked</a> item.</li>
 	// </ul>
 	// <h3>Subsubtitle</h3>
-	// <p>Some text<br>and more.</p>
+	// <p>Some&nbsp;text<br>and more.</p>
 	// </body>
 	// </html>
 }
@@ -121,6 +121,8 @@ func (p *htmlProcessor) SpecialToken(token markydown.SpecialToken) {
 		fmt.Print("<br>")
 	case markydown.SpecialTokenSpace:
 		fmt.Print(" ")
+	case markydown.SpecialTokenNonBreakingSpace:
+		fmt.Print("&nbsp;")
 	}
 }
 
@@ -0,0 +1,43 @@
+package markydown
+
+// Option is an option that changes how a document is parsed. Options can be
+// passed to Parse, ParseReader and friends.
+type Option func(o *options)
+
+// options stores the values of all parser options.
+type options struct {
+	maxHeadingLevel   int              // Maximum heading level recognized as such
+	diagnosticHandler func(Diagnostic) // Where diagnostics are reported to; nil if nowhere
+}
+
+// makeOptions returns the options resulting from applying a list of Options
+// to the default options.
+func makeOptions(opts []Option) options {
+	o := options{
+		maxHeadingLevel: maxHeadingLevel,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// MaxHeadingLevel returns an Option that sets the maximum heading level the
+// parser recognizes. Headings with more hashes than that are parsed as
+// regular text. Values are clamped to the [1, 6] range; the default is 6.
+//
+// Earlier versions of Markydown supported only headings up to level 3, so
+// MaxHeadingLevel(3) can be used to parse documents relying on `####` being
+// regular text.
+func MaxHeadingLevel(level int) Option {
+	return func(o *options) {
+		if level < 1 {
+			level = 1
+		} else if level > maxHeadingLevel {
+			level = maxHeadingLevel
+		}
+		o.maxHeadingLevel = level
+	}
+}
@@ -0,0 +1,304 @@
+package markydown
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// pandocAPIVersion is the version of the Pandoc JSON AST generated by the
+// PandocRenderer (the one used since Pandoc 3.0).
+var pandocAPIVersion = []int{1, 23, 1}
+
+// PandocRenderer is a Processor that renders a Markydown document as a Pandoc
+// JSON AST, writing the results to an io.Writer. This allows feeding
+// Markydown documents to Pandoc (as in `pandoc -f json`) and to Pandoc
+// filters.
+//
+// Headings become `Header`s, paragraphs become `Para`s, code blocks become
+// `CodeBlock`s (with the language as class, as Pandoc does), lists become
+// `BulletList`s and `OrderedList`s (whose items contain `Plain` blocks if the
+// list is tight), block quotes become `BlockQuote`s, and text becomes `Str`,
+// `Space`, `LineBreak`, `Emph`, `Strong`, `Code`, `Link` and `Image` inlines.
+// Non-breaking spaces are part of the `Str`s, just like Pandoc itself
+// represents them.
+//
+// The whole document is written at once, when the document ends.
+type PandocRenderer struct {
+	w    io.Writer   // Where the output goes to
+	err  error       // The first error found while writing, if any
+	tree treeBuilder // Builds the document tree we convert to JSON
+}
+
+// pandocElement is a Pandoc block or inline element, as represented in JSON.
+type pandocElement struct {
+	T string      `json:"t"`
+	C interface{} `json:"c,omitempty"`
+}
+
+// pandocDocument is a Pandoc document, as represented in JSON.
+type pandocDocument struct {
+	APIVersion []int                  `json:"pandoc-api-version"`
+	Meta       map[string]interface{} `json:"meta"`
+	Blocks     []pandocElement        `json:"blocks"`
+}
+
+// NewPandocRenderer creates a new PandocRenderer that writes its output to w.
+func NewPandocRenderer(w io.Writer) *PandocRenderer {
+	return &PandocRenderer{
+		w: w,
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *PandocRenderer) Err() error {
+	return r.err
+}
+
+// StartDocument implements the Processor interface.
+func (r *PandocRenderer) StartDocument() {
+	r.tree.StartDocument()
+}
+
+// EndDocument implements the Processor interface.
+func (r *PandocRenderer) EndDocument() {
+	doc := r.tree.doc
+	r.tree.EndDocument()
+
+	if doc == nil {
+		return // No document started
+	}
+
+	enc := json.NewEncoder(r.w)
+	enc.SetEscapeHTML(false)
+	r.err = enc.Encode(pandocDocument{
+		APIVersion: pandocAPIVersion,
+		Meta:       map[string]interface{}{},
+		Blocks:     pandocBlocks(doc.Children, false),
+	})
+}
+
+// StartParagraph implements the Processor interface.
+func (r *PandocRenderer) StartParagraph(parType ParType) {
+	r.tree.StartParagraph(parType)
+}
+
+// EndParagraph implements the Processor interface.
+func (r *PandocRenderer) EndParagraph(parType ParType) {
+	r.tree.EndParagraph(parType)
+}
+
+// Fragment implements the Processor interface.
+func (r *PandocRenderer) Fragment(text string) {
+	r.tree.Fragment(text)
+}
+
+// SpecialToken implements the Processor interface.
+func (r *PandocRenderer) SpecialToken(token SpecialToken) {
+	r.tree.SpecialToken(token)
+}
+
+// ChangeTextStyle implements the Processor interface.
+func (r *PandocRenderer) ChangeTextStyle(style TextStyle) {
+	r.tree.ChangeTextStyle(style)
+}
+
+// StartLink implements the Processor interface.
+func (r *PandocRenderer) StartLink(target string) {
+	r.tree.StartLink(target)
+}
+
+// EndLink implements the Processor interface.
+func (r *PandocRenderer) EndLink() {
+	r.tree.EndLink()
+}
+
+// Code implements the CodeProcessor interface.
+func (r *PandocRenderer) Code(text string) {
+	r.tree.Code(text)
+}
+
+// CodeBlockInfo implements the CodeProcessor interface.
+func (r *PandocRenderer) CodeBlockInfo(info string) {
+	r.tree.CodeBlockInfo(info)
+}
+
+// StartList implements the ListProcessor interface.
+func (r *PandocRenderer) StartList(list ListInfo) {
+	r.tree.StartList(list)
+}
+
+// EndList implements the ListProcessor interface.
+func (r *PandocRenderer) EndList(list ListInfo) {
+	r.tree.EndList(list)
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *PandocRenderer) StartListItem(list ListInfo) {
+	r.tree.StartListItem(list)
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *PandocRenderer) EndListItem(list ListInfo) {
+	r.tree.EndListItem(list)
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *PandocRenderer) StartQuote() {
+	r.tree.StartQuote()
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *PandocRenderer) EndQuote() {
+	r.tree.EndQuote()
+}
+
+// Image implements the ImageProcessor interface.
+func (r *PandocRenderer) Image(source, alt string) {
+	r.tree.Image(source, alt)
+}
+
+// pandocAttr returns an empty Pandoc Attr (identifier, classes and key-value
+// pairs).
+func pandocAttr() []interface{} {
+	return []interface{}{"", []string{}, [][]string{}}
+}
+
+// pandocBlocks converts a list of nodes to Pandoc blocks. Consecutive inline
+// nodes (which appear directly within list items) are grouped in a `Plain`
+// block if tight is true, or in a `Para` otherwise.
+func pandocBlocks(nodes []Node, tight bool) []pandocElement {
+	blocks := []pandocElement{}
+	var inlines []Node
+
+	flush := func() {
+		if len(inlines) == 0 {
+			return
+		}
+		t := "Para"
+		if tight {
+			t = "Plain"
+		}
+		blocks = append(blocks, pandocElement{T: t, C: pandocInlines(inlines)})
+		inlines = nil
+	}
+
+	for _, node := range nodes {
+		if !isBlockNode(node) {
+			inlines = append(inlines, node)
+			continue
+		}
+
+		flush()
+
+		switch n := node.(type) {
+		case *Heading:
+			blocks = append(blocks, pandocElement{
+				T: "Header",
+				C: []interface{}{n.Level, pandocAttr(), pandocInlines(n.Children)},
+			})
+
+		case *Paragraph:
+			blocks = append(blocks, pandocElement{T: "Para", C: pandocInlines(n.Children)})
+
+		case *CodeBlock:
+			attr := pandocAttr()
+			if language := codeLanguage(n.Info); language != "" {
+				attr[1] = []string{language}
+			}
+			blocks = append(blocks, pandocElement{T: "CodeBlock", C: []interface{}{attr, n.Text}})
+
+		case *Quote:
+			blocks = append(blocks, pandocElement{T: "BlockQuote", C: pandocBlocks(n.Children, false)})
+
+		case *BulletList:
+			blocks = append(blocks, pandocElement{T: "BulletList", C: pandocItems(n.Children, n.Tight)})
+
+		case *OrderedList:
+			attrs := []interface{}{n.Start, pandocElement{T: "Decimal"}, pandocElement{T: "Period"}}
+			blocks = append(blocks, pandocElement{
+				T: "OrderedList",
+				C: []interface{}{attrs, pandocItems(n.Children, n.Tight)},
+			})
+		}
+	}
+
+	flush()
+
+	return blocks
+}
+
+// pandocItems converts the items of a list to Pandoc, as a list of lists of
+// blocks.
+func pandocItems(items []Node, tight bool) [][]pandocElement {
+	result := [][]pandocElement{}
+	for _, item := range items {
+		if n, ok := item.(*ListItem); ok {
+			result = append(result, pandocBlocks(n.Children, tight))
+		}
+	}
+	return result
+}
+
+// pandocInlines converts a list of inline nodes to Pandoc inlines. Adjacent
+// texts are merged into a single `Str`, as Pandoc expects.
+func pandocInlines(nodes []Node) []pandocElement {
+	inlines := []pandocElement{}
+
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *Text:
+			inlines = appendPandocStr(inlines, n.Text)
+
+		case *NonBreakingSpace:
+			inlines = appendPandocStr(inlines, "\u00a0")
+
+		case *Code:
+			inlines = append(inlines, pandocElement{T: "Code", C: []interface{}{pandocAttr(), n.Text}})
+
+		case *SoftSpace:
+			inlines = append(inlines, pandocElement{T: "Space"})
+
+		case *HardBreak:
+			inlines = append(inlines, pandocElement{T: "LineBreak"})
+
+		case *Emphasis:
+			inlines = append(inlines, pandocElement{T: "Emph", C: pandocInlines(n.Children)})
+
+		case *Strong:
+			inlines = append(inlines, pandocElement{T: "Strong", C: pandocInlines(n.Children)})
+
+		case *Link:
+			inlines = append(inlines, pandocElement{
+				T: "Link",
+				C: []interface{}{pandocAttr(), pandocInlines(n.Children), []string{n.Target, ""}},
+			})
+
+		case *Image:
+			alt := []pandocElement{}
+			for i, word := range strings.Fields(n.Alt) {
+				if i > 0 {
+					alt = append(alt, pandocElement{T: "Space"})
+				}
+				alt = append(alt, pandocElement{T: "Str", C: word})
+			}
+			inlines = append(inlines, pandocElement{
+				T: "Image",
+				C: []interface{}{pandocAttr(), alt, []string{n.Source, ""}},
+			})
+		}
+	}
+
+	return inlines
+}
+
+// appendPandocStr appends a text to a list of Pandoc inlines, merging it into
+// the last `Str`, if that's the last inline.
+func appendPandocStr(inlines []pandocElement, text string) []pandocElement {
+	if last := len(inlines) - 1; last >= 0 && inlines[last].T == "Str" {
+		inlines[last].C = inlines[last].C.(string) + text
+		return inlines
+	}
+	return append(inlines, pandocElement{T: "Str", C: text})
+}
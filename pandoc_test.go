@@ -0,0 +1,68 @@
+package markydown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// renderPandoc renders a Markydown document as a Pandoc JSON AST.
+func renderPandoc(input string) string {
+	var buf bytes.Buffer
+	Parse(input, NewPandocRenderer(&buf))
+	return buf.String()
+}
+
+// Tests rendering Pandoc JSON ASTs.
+func TestPandocRenderer(t *testing.T) {
+	const (
+		prefix = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[`
+		suffix = "]}\n"
+		attr   = `["",[],[]]`
+	)
+
+	testData := map[string]string{
+		"": "",
+
+		"## Hi *there*": `{"t":"Header","c":[2,` + attr + `,[{"t":"Str","c":"Hi"},{"t":"Space"},` +
+			`{"t":"Emph","c":[{"t":"Str","c":"there"}]}]]}`,
+
+		"100\\ kg\\\n**[<a>](x)**": `{"t":"Para","c":[{"t":"Str","c":"100` + "\u00a0" + `kg"},{"t":"LineBreak"},` +
+			`{"t":"Strong","c":[{"t":"Link","c":[` + attr + `,[{"t":"Str","c":"<a>"}],["x",""]]}]}]}`,
+
+		"**strong *both***": `{"t":"Para","c":[{"t":"Strong","c":[{"t":"Str","c":"strong"},{"t":"Space"},` +
+			`{"t":"Emph","c":[{"t":"Str","c":"both"}]}]}]}`,
+
+		"*a **b***": `{"t":"Para","c":[{"t":"Emph","c":[{"t":"Str","c":"a"},{"t":"Space"},` +
+			`{"t":"Strong","c":[{"t":"Str","c":"b"}]}]}]}`,
+
+		"Use `a*b`": `{"t":"Para","c":[{"t":"Str","c":"Use"},{"t":"Space"},{"t":"Code","c":[` + attr + `,"a*b"]}]}`,
+
+		"![A *big* cat](c.png)": `{"t":"Para","c":[{"t":"Image","c":[` + attr + `,[{"t":"Str","c":"A"},{"t":"Space"},` +
+			`{"t":"Str","c":"big"},{"t":"Space"},{"t":"Str","c":"cat"}],["c.png",""]]}]}`,
+
+		"```go main\nx\n```": `{"t":"CodeBlock","c":[["",["go"],[]],"x"]}`,
+
+		"> > A\n>\n> B\n\n>": `{"t":"BlockQuote","c":[{"t":"BlockQuote","c":[{"t":"Para","c":[{"t":"Str","c":"A"}]}]},` +
+			`{"t":"Para","c":[{"t":"Str","c":"B"}]}]},{"t":"BlockQuote","c":[]}`,
+
+		"+ One\n+ Two": `{"t":"BulletList","c":[[{"t":"Plain","c":[{"t":"Str","c":"One"}]}],` +
+			`[{"t":"Plain","c":[{"t":"Str","c":"Two"}]}]]}`,
+
+		"3. One\n\n    + Two\n\n4. Three": `{"t":"OrderedList","c":[[3,{"t":"Decimal"},{"t":"Period"}],[` +
+			`[{"t":"Para","c":[{"t":"Str","c":"One"}]},{"t":"BulletList","c":[[{"t":"Plain","c":[{"t":"Str","c":"Two"}]}]]}],` +
+			`[{"t":"Para","c":[{"t":"Str","c":"Three"}]}]]]}`,
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, renderPandoc(input), prefix+expected+suffix)
+	}
+}
+
+// Tests if write errors are reported.
+func TestPandocRendererError(t *testing.T) {
+	r := NewPandocRenderer(failingWriter{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
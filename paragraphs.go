@@ -1,6 +1,7 @@
 package markydown
 
 import (
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -12,8 +13,8 @@ import (
 // the reason why it doesn't return a Boolean indicating success or failure.
 func (p *parser) parseTextParagraph() {
 
-	p.processor.StartParagraph(ParTypeText)
-	defer p.processor.EndParagraph(ParTypeText)
+	p.startParagraph(ParTypeText)
+	defer p.endParagraph(ParTypeText)
 
 	p.parseParagraphContents()
 }
@@ -21,65 +22,97 @@ func (p *parser) parseTextParagraph() {
 // parseHeading parses a heading (of any supported level). Returns true if the
 // parsing succeeded or false otherwise (in which case no input is consumed).
 func (p *parser) parseHeading() bool {
-	parType := ParTypeInvalid
-
 	firstSpace := strings.IndexFunc(p.input, isHorizontalSpace)
+	level := len(p.input) - len(strings.TrimLeftFunc(p.input, isHeading))
 
-	if strings.HasPrefix(p.input, "###") && firstSpace == 3 {
-		parType = ParTypeHeading3
-	} else if strings.HasPrefix(p.input, "##") && firstSpace == 2 {
-		parType = ParTypeHeading2
-	} else if strings.HasPrefix(p.input, "#") && firstSpace == 1 {
-		parType = ParTypeHeading1
+	if level == 0 || firstSpace != level {
+		return false
 	}
 
-	if parType == ParTypeInvalid {
+	if level > p.opts.maxHeadingLevel {
+		p.diagnose(SeverityWarning, p.offset(), p.offset()+level, CodeHeadingTooDeep,
+			"heading level "+strconv.Itoa(level)+" is not supported (the maximum is "+
+				strconv.Itoa(p.opts.maxHeadingLevel)+"); parsing it as regular text")
 		return false
 	}
 
+	parType := headingParType(level)
+
 	p.input = p.input[firstSpace:]
 	p.consumeRawHorizontalSpaces()
 
-	p.processor.StartParagraph(parType)
-	defer p.processor.EndParagraph(parType)
+	p.startParagraph(parType)
+	defer p.endParagraph(parType)
 
+	contentsStart := p.offset()
 	p.parseParagraphContents()
+	p.diagnoseTrailingHashes(contentsStart)
 
 	return true
 }
 
-// parseBulletedParagraph parses a paragraph that is a bulleted list item.
-// Returns true if the parsing succeeded or false otherwise (in which case no
-// input is consumed).
-func (p *parser) parseBulletedParagraph() bool {
-	firstSpace := strings.IndexFunc(p.input, isHorizontalSpace)
+// diagnoseTrailingHashes reports hashes at the end of a heading whose contents
+// started at offset contentsStart, as in `## Heading ##`.
+func (p *parser) diagnoseTrailingHashes(contentsStart int) {
+	if p.lastEnd <= contentsStart {
+		return
+	}
+
+	contents := p.buf[contentsStart-p.bufStart : p.lastEnd-p.bufStart]
+	text := strings.TrimRight(contents, "#")
+	hashes := len(contents) - len(text)
 
-	r, w := utf8.DecodeRuneInString(p.input)
+	if hashes == 0 || len(text) == 0 {
+		return
+	}
 
-	if !isBullet(r) || firstSpace != w {
+	if r, _ := utf8.DecodeLastRuneInString(text); !isHorizontalSpace(r) && !isNewLine(r) {
+		return
+	}
+
+	p.diagnose(SeverityWarning, p.lastEnd-hashes, p.lastEnd, CodeHeadingTrailingHashes,
+		"trailing `#`s are not supported in headings; they are part of the heading text")
+}
+
+// parseListItem parses a paragraph that is a list item (either bulleted or
+// ordered). Returns true if the parsing succeeded or false otherwise (in which
+// case no input is consumed).
+func (p *parser) parseListItem() bool {
+	kind, number, w := listMarker(p.input)
+	if w == 0 {
 		return false
 	}
 
+	p.enterListItem(p.parIndent, kind, number)
+
+	p.inListItem = true
+	defer func() { p.inListItem = false }()
+
 	p.input = p.input[w:]
 	p.consumeRawHorizontalSpaces()
 
-	p.processor.StartParagraph(ParTypeBulletedList)
-	defer p.processor.EndParagraph(ParTypeBulletedList)
+	parType := ParTypeBulletedList
+	if kind == ListKindOrdered {
+		parType = ParTypeOrderedList
+	}
+
+	p.startParagraph(parType)
+	defer p.endParagraph(parType)
 
 	p.parseParagraphContents()
 
 	return true
 }
 
-// parseParagraphContents parses the contents of a paragraph. The input must be
-// on the first character of the contents (that is, things like `# ` and `+ `
-// that mark the paragraph type must have been consumed already).
+// parseParagraphContents parses the contents of a paragraph. Things like `# `
+// and `+ ` that mark the paragraph type must have been consumed already. Spaces
+// before the contents (which may even start on the next line) are skipped.
 func (p *parser) parseParagraphContents() {
 
-	p.frag = p.input
-	p.fragEnd = 0
+	p.consumeRawSpacesWithinParagraph()
 
 	for initialLen := len(p.input); ; initialLen = len(p.input) {
+		tokenStart := p.offset()
 		theType, isEscaped := p.nextRune()
 
 		switch theType {
@@ -88,38 +121,46 @@ func (p *parser) parseParagraphContents() {
 			p.emitFragment()
 			p.consumeRawSpacesWithinParagraph()
 			if p.paragraphGoesOn() && !p.isHardLineBreakAhead() {
+				p.at(tokenStart, p.offset())
 				p.processor.SpecialToken(SpecialTokenSpace)
 			}
 
+		case runeTypeNonBreakingSpace:
+			p.emitFragment()
+			p.at(tokenStart, p.offset())
+			p.processor.SpecialToken(SpecialTokenNonBreakingSpace)
+
 		case runeTypeEmphasis:
 			p.emitFragment()
 
-			if p.textStyle == TextStyleEmphasis {
-				p.textStyle = TextStyleRegular
-			} else {
-				p.textStyle = TextStyleEmphasis
-			}
+			p.toggleStyle(TextStyleEmphasis, tokenStart)
 
+			p.at(tokenStart, p.offset())
 			p.processor.ChangeTextStyle(p.textStyle)
 
 		case runeTypeStrongEmphasis:
 			p.emitFragment()
 
-			if p.textStyle == TextStyleStrong {
-				p.textStyle = TextStyleRegular
-			} else {
-				p.textStyle = TextStyleStrong
-			}
+			p.toggleStyle(TextStyleStrong, tokenStart)
 
+			p.at(tokenStart, p.offset())
 			p.processor.ChangeTextStyle(p.textStyle)
 
 		case runeTypeNewLine:
 			p.emitFragment()
 			p.consumeRawHorizontalSpaces()
 
+			itemAhead := !isEscaped && p.isListItemAhead()
+
+			if !isEscaped && !itemAhead && p.paragraphGoesOn() {
+				p.diagnoseListItemInText()
+			}
+
 			if isEscaped {
+				p.at(tokenStart, p.offset())
 				p.processor.SpecialToken(SpecialTokenLineBreak)
-			} else if p.paragraphGoesOn() {
+			} else if p.paragraphGoesOn() && !itemAhead && !p.isHardLineBreakAhead() {
+				p.at(tokenStart, p.offset())
 				p.processor.SpecialToken(SpecialTokenSpace)
 			}
 
@@ -132,15 +173,30 @@ func (p *parser) parseParagraphContents() {
 				// Two consecutive new lines: we reached the end of the paragraph
 				return
 			}
+			if itemAhead {
+				// A new list item ends the current one, even without a blank line
+				return
+			}
 
 		case runeTypeLinkStart:
 			p.emitFragment()
+			p.at(tokenStart, p.offset())
 			p.processor.StartLink(p.linkTarget)
 
 		case runeTypeLinkEnd:
 			p.emitFragment()
-			p.processor.EndLink()
 			p.consumeLinkTarget()
+			p.at(tokenStart, p.offset())
+			p.processor.EndLink()
+
+		case runeTypeCode:
+			p.emitFragment()
+			p.at(tokenStart, p.offset())
+			p.code(p.codeText)
+
+		case runeTypeImage:
+			p.emitFragment()
+			p.image(tokenStart, p.imageSource, p.imageAlt)
 
 		case runeTypeEOI:
 			p.emitFragment()
@@ -152,6 +208,61 @@ func (p *parser) parseParagraphContents() {
 				p.fragEnd--
 			}
 			p.fragEnd += initialLen - len(p.input)
+			p.fragSrcEnd = p.offset()
 		}
 	}
 }
+
+// toggleStyle turns a given text style on or off, as requested by a marker
+// that starts at offset start and ends at the current input position. (The
+// processor is not notified.)
+func (p *parser) toggleStyle(style TextStyle, start int) {
+	p.textStyle ^= style
+
+	if p.opts.diagnosticHandler == nil {
+		return
+	}
+
+	if p.styleMarks == nil {
+		p.styleMarks = make(map[TextStyle]int)
+	}
+
+	if p.textStyle.Has(style) {
+		p.styleMarks[style] = start
+	} else {
+		delete(p.styleMarks, style)
+	}
+}
+
+// diagnoseOpenStyles reports the markers of the text styles left open at the
+// end of the current paragraph, in the order they appear in the input.
+func (p *parser) diagnoseOpenStyles() {
+	styles := textStyles
+	if p.styleMarks[styles[1]] < p.styleMarks[styles[0]] {
+		styles = []TextStyle{styles[1], styles[0]}
+	}
+
+	for _, style := range styles {
+		start, ok := p.styleMarks[style]
+		if !ok || !p.textStyle.Has(style) {
+			continue
+		}
+
+		marker := "*"
+		if style == TextStyleStrong {
+			marker = "**"
+		}
+
+		p.diagnose(SeverityWarning, start, start+len(marker), CodeUnbalancedEmphasis,
+			"`"+marker+"` is never closed; closing it at the end of the paragraph")
+	}
+}
+
+// diagnoseListItemInText reports a list marker at the current input position,
+// which is the start of a line in the middle of a paragraph.
+func (p *parser) diagnoseListItemInText() {
+	if _, _, markerLen := listMarker(p.input); markerLen > 0 {
+		p.diagnose(SeverityWarning, p.offset(), p.offset()+markerLen, CodeListItemInText,
+			"list items must be preceded by a blank line or another list item; parsing it as regular text")
+	}
+}
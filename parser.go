@@ -1,39 +1,119 @@
 package markydown
 
+import (
+	"bufio"
+	"strings"
+)
+
 // Parse parses a Markydown document passed as a string and lets the passed
 // Processor do its work as the document is parsed.
 //
 // It works in the same spirit as the Template Method design pattern.
-func Parse(document string, processor Processor) {
+func Parse(document string, processor Processor, options ...Option) {
+	p := newParser(processor, options)
+	p.buf = document
+	p.input = document
+
+	if p.lines != nil {
+		p.lines.add(document, 0)
+	}
+
+	p.parseDocument()
+}
+
+// newParser creates a new parser, ready to parse a document (as soon as its
+// input is set up).
+func newParser(processor Processor, options []Option) *parser {
 	p := &parser{
-		input:     document,
 		processor: processor,
-		frag:      document,
 		textStyle: TextStyleRegular,
+		opts:      makeOptions(options),
 	}
 
-	p.parseDocument()
+	if pp, ok := processor.(PositionedProcessor); ok {
+		p.positioned = pp
+	}
+
+	if p.positioned != nil || p.opts.diagnosticHandler != nil {
+		p.lines = &lineIndex{starts: []int{0}}
+	}
+
+	if lp, ok := processor.(ListProcessor); ok {
+		p.lister = lp
+	}
+
+	if cp, ok := processor.(CodeProcessor); ok {
+		p.coder = cp
+	}
+
+	if qp, ok := processor.(QuoteProcessor); ok {
+		p.quoter = qp
+	}
+
+	if ip, ok := processor.(ImageProcessor); ok {
+		p.imager = ip
+	}
+
+	return p
 }
 
 // parser stores all the parsing state.
 type parser struct {
-	input         string    // The input that was not consumed yet.
-	processor     Processor // Processor processing the parsed data.
-	frag          string    // The current text fragment being parsed, along with the rest of the input
-	fragEnd       int       // Index into frag indicating the end of fragment being parsed
-	textStyle     TextStyle // The current text style
-	linkTarget    string    // The current link target; if empty, we are not parsing a link
-	linkTargetLen int       // The length of the target link in the input string (may be greater than len(linkTarget), because of escapes)
+	buf           string          // The whole input buffer
+	bufBuilder    strings.Builder // Where buf is built when reading from reader
+	bufStart      int             // Offset into the input document where buf starts
+	input         string          // The input that was not consumed yet (a suffix of buf).
+	reader        *bufio.Reader   // Where more input comes from; nil if all input is in buf
+	err           error           // The first error that happened while reading from reader
+	breakOffset   int             // Offset right after the last blank line read from reader
+	processor     Processor       // Processor processing the parsed data.
+	opts          options         // The options in effect
+	frag          string          // The current text fragment being parsed, along with the rest of the input
+	fragEnd       int             // Index into frag indicating the end of fragment being parsed
+	textStyle     TextStyle       // The current text style
+	linkTarget    string          // The current link target; if empty, we are not parsing a link
+	linkTargetLen int             // The length of the target link in the input string (may be greater than len(linkTarget), because of escapes)
+	linkTextLen   int             // The length of the link text (between the brackets) found by the last link look ahead
+	codeText      string          // The text of the code span just lexed
+	imageSource   string          // The source of the image just lexed
+	imageAlt      string          // The alternative text of the image just lexed
+	coder         CodeProcessor   // The processor, if it wants to know about code spans; nil otherwise
+	lister        ListProcessor   // The processor, if it wants to know about lists; nil otherwise
+	lists         []openList      // Stack of lists we are currently in
+	inListItem    bool            // Are we parsing a list item paragraph?
+
+	positioned PositionedProcessor // The processor, if it wants positions; nil otherwise
+	lines      *lineIndex          // Line index for buf; only set if positioned != nil or diagnostics are wanted
+	styleMarks map[TextStyle]int   // Offset where each style in effect was opened; only set if diagnostics are wanted
+	badLinkEnd int                 // Offset of the `]` of the last malformed link reported
+	fragStart  int                 // Offset where the current fragment starts in the input
+	fragSrcEnd int                 // Offset where the current fragment ends in the input
+	parStart   int                 // Offset where the current paragraph starts in the input
+	parIndent  int                 // Indentation of the current paragraph
+	lastEnd    int                 // Offset where the last reported element ends in the input
+	quoter     QuoteProcessor      // The processor, if it wants to know about block quotes; nil otherwise
+	quote      *quoteText          // The block quote whose text we are parsing; nil if parsing a whole document
+	imager     ImageProcessor      // The processor, if it wants to know about images; nil otherwise
 }
 
 // parseDocument parses the whole Markydown document.
 func (p *parser) parseDocument() {
+	p.at(0, 0)
 	p.processor.StartDocument()
-	defer p.processor.EndDocument()
 
+	p.parseBlocks()
+
+	p.at(p.offset(), p.offset())
+	p.processor.EndDocument()
+}
+
+// parseBlocks parses paragraphs until the end of the input is reached.
+func (p *parser) parseBlocks() {
 	for p.parseAnyParagraph() {
 		continue
 	}
+
+	p.closeLists(0)
 }
 
 // parseAnyParagraph detects the type of the next paragraph on the input and
@@ -43,17 +123,39 @@ func (p *parser) parseDocument() {
 // input was reached.
 func (p *parser) parseAnyParagraph() bool {
 	// Chomp spaces, check if something is left
-	p.consumeRawSpaces()
-	if len(p.input) == 0 {
+	for p.consumeRawSpaces(); len(p.input) == 0; p.consumeRawSpaces() {
+		if !p.fill() {
+			return false
+		}
+	}
+
+	p.parIndent = p.indentation()
+	p.readParagraph()
+	p.parStart = p.offset()
+
+	// A rogue backslash at the end of the input is ignored, so it doesn't make
+	// an empty paragraph
+	if isRogueEscape(p.input) {
+		p.input = ""
 		return false
 	}
 
 	// Try parsing each of the "special" paragraph types.
-	if p.parseHeading() {
+	if p.parseListItem() {
+		return true
+	}
+
+	p.closeLists(0)
+
+	if p.parseQuote() {
+		return true
+	}
+
+	if p.parseCodeBlock() {
 		return true
 	}
 
-	if p.parseBulletedParagraph() {
+	if p.parseHeading() {
 		return true
 	}
 
@@ -62,6 +164,37 @@ func (p *parser) parseAnyParagraph() bool {
 	return true
 }
 
+// startParagraph tells the processor that a paragraph of a given type is
+// starting. The paragraph marker (if any) must have been consumed already.
+func (p *parser) startParagraph(parType ParType) {
+	p.at(p.parStart, p.offset())
+	p.processor.StartParagraph(parType)
+}
+
+// endParagraph tells the processor that the current paragraph has ended.
+//
+// Text styles left open are closed before that, so that every paragraph starts
+// with the regular style.
+func (p *parser) endParagraph(parType ParType) {
+	if p.textStyle != TextStyleRegular {
+		p.diagnoseOpenStyles()
+		p.at(p.lastEnd, p.lastEnd)
+		p.textStyle = TextStyleRegular
+		p.processor.ChangeTextStyle(p.textStyle)
+	}
+
+	p.at(p.parStart, p.lastEnd)
+	p.processor.EndParagraph(parType)
+}
+
+// startFragment resets the fragment-related parser state, so that a new
+// fragment starts at the current point in the input.
+func (p *parser) startFragment() {
+	p.frag = p.input
+	p.fragEnd = 0
+	p.fragStart = p.offset()
+}
+
 // emitFragment tells the processor that the current text fragment was parsed
 // and resets the fragment-related parser state, in order to make it ready to
 // parse a new fragment.
@@ -71,9 +204,52 @@ func (p *parser) parseAnyParagraph() bool {
 // the input.
 func (p *parser) emitFragment() {
 	if p.fragEnd > 0 {
+		p.at(p.fragStart, p.fragSrcEnd)
 		p.processor.Fragment(p.frag[:p.fragEnd])
 	}
 
-	p.fragEnd = 0
-	p.frag = p.input
+	p.startFragment()
+}
+
+// offset returns the current offset into the input document.
+func (p *parser) offset() int {
+	return p.offsetOf(p.input)
+}
+
+// offsetOf returns the offset into the input document where s starts. s must
+// be a suffix of the input buffer.
+func (p *parser) offsetOf(s string) int {
+	return p.bufStart + len(p.buf) - len(s)
+}
+
+// at tells the processor (if it wants to know) that the next thing reported
+// to it comes from the input between offsets start and end. (When parsing the
+// text of a block quote, this is passed along to the parser of the outer
+// document, which knows where the text came from.)
+func (p *parser) at(start, end int) {
+	p.lastEnd = end
+
+	if p.quote != nil {
+		p.quote.outer.at(p.quote.outerOffset(start), p.quote.outerOffset(end))
+		return
+	}
+
+	if p.positioned == nil {
+		return
+	}
+
+	p.positioned.SourceSpan(p.span(start, end))
+}
+
+// span returns the Span between offsets start and end of the input. This
+// requires the line index, so it must only be called when positions or
+// diagnostics are wanted.
+func (p *parser) span(start, end int) Span {
+	line, column := p.lines.position(start)
+	return Span{
+		StartOffset: start,
+		EndOffset:   end,
+		Line:        line,
+		Column:      column,
+	}
 }
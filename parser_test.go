@@ -1,6 +1,8 @@
 package markydown
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/lmbarros/sbxs_go_test/assert"
@@ -22,8 +24,18 @@ func parTypeToString(parType ParType) string {
 		return "H2"
 	case ParTypeHeading3:
 		return "H3"
+	case ParTypeHeading4:
+		return "H4"
+	case ParTypeHeading5:
+		return "H5"
+	case ParTypeHeading6:
+		return "H6"
 	case ParTypeBulletedList:
 		return "UL"
+	case ParTypeOrderedList:
+		return "OL"
+	case ParTypeCodeBlock:
+		return "CB"
 	default:
 		return "<WTF?!>"
 	}
@@ -37,6 +49,8 @@ func specialTokenToString(token SpecialToken) string {
 		return "SP"
 	case SpecialTokenLineBreak:
 		return "NL"
+	case SpecialTokenNonBreakingSpace:
+		return "NB"
 	default:
 		return "<WTF?!>"
 	}
@@ -52,6 +66,8 @@ func textStyleToString(style TextStyle) string {
 		return "EM"
 	case TextStyleStrong:
 		return "ST"
+	case TextStyleEmphasis | TextStyleStrong:
+		return "EM+ST"
 	default:
 		return "<WTF?!>"
 	}
@@ -106,6 +122,61 @@ func (p *testProcessor) EndLink() {
 	p.res = append(p.res, "EL")
 }
 
+func (p *testProcessor) Code(text string) {
+	p.res = append(p.res, "C-"+text)
+}
+
+func (p *testProcessor) CodeBlockInfo(info string) {
+	p.res = append(p.res, "CI-"+info)
+}
+
+func (p *testProcessor) StartQuote() {
+	p.res = append(p.res, "SQ")
+}
+
+func (p *testProcessor) EndQuote() {
+	p.res = append(p.res, "EQ")
+}
+
+func (p *testProcessor) Image(source, alt string) {
+	p.res = append(p.res, "IM-"+source+"-"+alt)
+}
+
+// listInfoToString converts a given ListInfo to a string value, as used by the
+// listProcessor. Ordered lists get the starting number after a hash sign, and
+// tight lists get a "T" at the end.
+func listInfoToString(list ListInfo) string {
+	s := fmt.Sprintf("%v", list.Depth)
+	if list.Kind == ListKindOrdered {
+		s += fmt.Sprintf("#%v", list.Start)
+	}
+	if list.Tight {
+		s += "T"
+	}
+	return s
+}
+
+// listProcessor is a testProcessor that is also a ListProcessor.
+type listProcessor struct {
+	testProcessor
+}
+
+func (p *listProcessor) StartList(list ListInfo) {
+	p.res = append(p.res, "LS-"+listInfoToString(list))
+}
+
+func (p *listProcessor) EndList(list ListInfo) {
+	p.res = append(p.res, "LE-"+listInfoToString(list))
+}
+
+func (p *listProcessor) StartListItem(list ListInfo) {
+	p.res = append(p.res, "IS-"+listInfoToString(list))
+}
+
+func (p *listProcessor) EndListItem(list ListInfo) {
+	p.res = append(p.res, "IE-"+listInfoToString(list))
+}
+
 //
 // Real tests start here
 //
@@ -123,6 +194,10 @@ func TestParseBlanks(t *testing.T) {
 		"\r",
 		"\n  ",
 		"   \t\n\n \t\n    \t    \n\r  \r\n\t  ",
+
+		// A rogue backslash in the end of the input doesn't make a paragraph
+		"\\",
+		"\n\t\\",
 	}
 
 	for _, v := range inputs {
@@ -143,7 +218,8 @@ func TestParseSingleWord(t *testing.T) {
 		"\ndö\\rt \n": {"SD", "SP-P", "F-dört", "EP-P", "ED"},
 
 		// A rogue backslash in the end of the input should be OK
-		"fünf\\": {"SD", "SP-P", "F-fünf", "EP-P", "ED"},
+		"fünf\\":   {"SD", "SP-P", "F-fünf", "EP-P", "ED"},
+		"sechs \\": {"SD", "SP-P", "F-sechs", "EP-P", "ED"},
 	}
 
 	for input, expected := range testData {
@@ -169,13 +245,18 @@ func TestParseSimpleParagraphs(t *testing.T) {
 		// Need a space after the hash sign to be recognized as heading
 		"#I've\n\tgot\t": {"SD", "SP-P", "F-#I've", "ST-SP", "F-got", "EP-P", "ED"},
 
-		// Recognize only up to level-3 heading
-		"#### a perfect\n": {"SD", "SP-P", "F-####", "ST-SP", "F-a", "ST-SP", "F-perfect", "EP-P", "ED"},
+		// Recognize only up to level-6 heading
+		"#### a perfect\n": {"SD", "SP-H4", "F-a", "ST-SP", "F-perfect", "EP-H4", "ED"},
+		"####### day\n":    {"SD", "SP-P", "F-#######", "ST-SP", "F-day", "EP-P", "ED"},
 
 		// Bulleted lists
 		"+ Puzzle\n\t for\n\n": {"SD", "SP-UL", "F-Puzzle", "ST-SP", "F-for", "EP-UL", "ED"},
 		"+   \t you.":          {"SD", "SP-UL", "F-you.", "EP-UL", "ED"},
 
+		// Contents may start on the next line
+		"# \nOompa":  {"SD", "SP-H1", "F-Oompa", "EP-H1", "ED"},
+		"+\t\n  you": {"SD", "SP-UL", "F-you", "EP-UL", "ED"},
+
 		// An actual list item requires a space after the bullet sign
 		"+Só!": {"SD", "SP-P", "F-+Só!", "EP-P", "ED"},
 	}
@@ -187,6 +268,28 @@ func TestParseSimpleParagraphs(t *testing.T) {
 	}
 }
 
+// Tests parsing headings with different maximum heading levels.
+func TestParseHeadingLevels(t *testing.T) {
+	input := "# 1\n\n## 2\n\n### 3\n\n#### 4\n\n##### 5\n\n###### 6"
+
+	testData := map[int][]string{
+		6: {"SD", "SP-H1", "F-1", "EP-H1", "SP-H2", "F-2", "EP-H2", "SP-H3", "F-3", "EP-H3",
+			"SP-H4", "F-4", "EP-H4", "SP-H5", "F-5", "EP-H5", "SP-H6", "F-6", "EP-H6", "ED"},
+		3: {"SD", "SP-H1", "F-1", "EP-H1", "SP-H2", "F-2", "EP-H2", "SP-H3", "F-3", "EP-H3",
+			"SP-P", "F-####", "ST-SP", "F-4", "EP-P", "SP-P", "F-#####", "ST-SP", "F-5", "EP-P",
+			"SP-P", "F-######", "ST-SP", "F-6", "EP-P", "ED"},
+		0: {"SD", "SP-H1", "F-1", "EP-H1", "SP-P", "F-##", "ST-SP", "F-2", "EP-P",
+			"SP-P", "F-###", "ST-SP", "F-3", "EP-P", "SP-P", "F-####", "ST-SP", "F-4", "EP-P",
+			"SP-P", "F-#####", "ST-SP", "F-5", "EP-P", "SP-P", "F-######", "ST-SP", "F-6", "EP-P", "ED"},
+	}
+
+	for maxLevel, expected := range testData {
+		p := &testProcessor{}
+		Parse(input, p, MaxHeadingLevel(maxLevel))
+		assert.Equal(t, p.res, expected)
+	}
+}
+
 // Tests parsing some simple formatting.
 func TestParseSimpleFormatting(t *testing.T) {
 	testData := map[string][]string{
@@ -205,6 +308,18 @@ func TestParseSimpleFormatting(t *testing.T) {
 		"\\*Now!\\*":   {"SD", "SP-P", "F-*Now!*", "EP-P", "ED"},
 		"*\\*Now!\\**": {"SD", "SP-P", "TS-EM", "F-*Now!*", "TS-RE", "EP-P", "ED"},
 		"\\**Now!\\**": {"SD", "SP-P", "F-*", "TS-EM", "F-Now!*", "TS-RE", "EP-P", "ED"},
+
+		// Styles left open are closed at the end of the paragraph
+		"*One\n\nTwo": {"SD", "SP-P", "TS-EM", "F-One", "TS-RE", "EP-P", "SP-P", "F-Two", "EP-P", "ED"},
+		"# **One *":   {"SD", "SP-H1", "TS-ST", "F-One", "ST-SP", "TS-EM+ST", "TS-RE", "EP-H1", "ED"},
+
+		// Styles can be combined
+		"**bold *and italic***": {"SD", "SP-P", "TS-ST", "F-bold", "ST-SP", "TS-EM+ST", "F-and",
+			"ST-SP", "F-italic", "TS-EM", "TS-RE", "EP-P", "ED"},
+		"***Both** then *one": {"SD", "SP-P", "TS-ST", "TS-EM+ST", "F-Both", "TS-EM",
+			"ST-SP", "F-then", "ST-SP", "TS-RE", "F-one", "EP-P", "ED"},
+		"*a **b* c**": {"SD", "SP-P", "TS-EM", "F-a", "ST-SP", "TS-EM+ST", "F-b", "TS-ST",
+			"ST-SP", "F-c", "TS-RE", "EP-P", "ED"},
 	}
 
 	for input, expected := range testData {
@@ -225,9 +340,13 @@ func TestParseLinks(t *testing.T) {
 		"+ Click [here, *please*!](the*tárgeτ*)": {"SD", "SP-UL", "F-Click", "ST-SP", "SL-the*tárgeτ*",
 			"F-here,", "ST-SP", "TS-EM", "F-please", "TS-RE", "F-!", "EL", "EP-UL", "ED"},
 
+		// Links with empty targets are just text
+		"[Empty]()": {"SD", "SP-P", "F-[Empty]()", "EP-P", "ED"},
+
 		// Tricky escaped characters
 		"### Click\\[ [her\\]e](t\\(arg\\)et).": {"SD", "SP-H3", "F-Click[", "ST-SP", "SL-t(arg)et",
 			"F-her]e", "EL", "F-.", "EP-H3", "ED"},
+		"[a](b\\é\\τx)": {"SD", "SP-P", "SL-béτx", "F-a", "EL", "EP-P", "ED"},
 
 		// Unclosed links must be handled as a regular text
 		"[here": {"SD", "SP-P", "F-[here", "EP-P", "ED"},
@@ -235,6 +354,13 @@ func TestParseLinks(t *testing.T) {
 
 		// Targetless link is recognized as regular text.
 		"[Kafka]": {"SD", "SP-P", "F-[Kafka]", "EP-P", "ED"},
+
+		// Links cannot span paragraphs
+		"[a\n\nb](c)": {"SD", "SP-P", "F-[a", "EP-P", "SP-P", "F-b](c)", "EP-P", "ED"},
+		"[a](b\n\nc)": {"SD", "SP-P", "F-[a](b", "EP-P", "SP-P", "F-c)", "EP-P", "ED"},
+
+		// Links cannot be nested
+		"[a [b](c) d](e)": {"SD", "SP-P", "SL-c", "F-a", "ST-SP", "F-[b", "EL", "ST-SP", "F-d](e)", "EP-P", "ED"},
 	}
 
 	for input, expected := range testData {
@@ -244,6 +370,223 @@ func TestParseLinks(t *testing.T) {
 	}
 }
 
+// Tests if every StartLink is followed by an EndLink before the next one, even
+// with brackets nested within the text of links.
+func TestParseLinksBalanced(t *testing.T) {
+	inputs := []string{
+		"[a [b](c) d](e)",
+		"[a ![b](c) d](e)",
+		"[[a](b)](c)",
+		"[a [b] c](d)",
+		"[a ![b] c](d) [e](f)",
+		"[![a](b)](c) ![d](e)",
+		"+ [a [b](c)\n+ d](e)",
+	}
+
+	for _, input := range inputs {
+		p := &testProcessor{}
+		Parse(input, p)
+
+		open := false
+		for _, call := range p.res {
+			if strings.HasPrefix(call, "SL-") {
+				assert.Equal(t, open, false)
+				open = true
+			} else if call == "EL" {
+				assert.Equal(t, open, true)
+				open = false
+			}
+		}
+		assert.Equal(t, open, false)
+	}
+}
+
+// Tests parsing some code spans.
+func TestParseCode(t *testing.T) {
+	testData := map[string][]string{
+		// Nothing special inside code spans
+		"Call `Parse(*doc*)`.": {"SD", "SP-P", "F-Call", "ST-SP", "C-Parse(*doc*)", "F-.", "EP-P", "ED"},
+		"`[a](b) \\\\ \\`":     {"SD", "SP-P", "C-[a](b) \\\\ \\", "EP-P", "ED"},
+		"*a `b* c`*":           {"SD", "SP-P", "TS-EM", "F-a", "ST-SP", "C-b* c", "TS-RE", "EP-P", "ED"},
+		"`a  \tb`":             {"SD", "SP-P", "C-a  \tb", "EP-P", "ED"},
+
+		// Backticks inside code spans
+		"``a ` b``":       {"SD", "SP-P", "C-a ` b", "EP-P", "ED"},
+		"`` `a` ``":       {"SD", "SP-P", "C-`a`", "EP-P", "ED"},
+		"```a``b````c```": {"SD", "SP-P", "C-a``b````c", "EP-P", "ED"},
+		"` `` `":          {"SD", "SP-P", "C-``", "EP-P", "ED"},
+
+		// Only one space is removed from each side, and only from both sides
+		"`  a  `": {"SD", "SP-P", "C- a ", "EP-P", "ED"},
+		"` a`":    {"SD", "SP-P", "C- a", "EP-P", "ED"},
+		"`  `":    {"SD", "SP-P", "C-  ", "EP-P", "ED"},
+
+		// New lines are spaces
+		"+ `a\n    b`": {"SD", "SP-UL", "C-a b", "EP-UL", "ED"},
+
+		// Code spans take precedence over links
+		"[a `]` b](c)": {"SD", "SP-P", "SL-c", "F-a", "ST-SP", "C-]", "ST-SP", "F-b", "EL", "EP-P", "ED"},
+		"[a `b](c)`":   {"SD", "SP-P", "F-[a", "ST-SP", "C-b](c)", "EP-P", "ED"},
+
+		// Unclosed backticks are just text
+		"a `b":       {"SD", "SP-P", "F-a", "ST-SP", "F-`b", "EP-P", "ED"},
+		"``a` b":     {"SD", "SP-P", "F-``a`", "ST-SP", "F-b", "EP-P", "ED"},
+		"\\\\`a`":    {"SD", "SP-P", "F-\\", "C-a", "EP-P", "ED"},
+		"\\`a\\`":    {"SD", "SP-P", "F-`a`", "EP-P", "ED"},
+		"`a\n\nb`":   {"SD", "SP-P", "F-`a", "EP-P", "SP-P", "F-b`", "EP-P", "ED"},
+		"+ `a\n+ b`": {"SD", "SP-UL", "F-`a", "EP-UL", "SP-UL", "F-b`", "EP-UL", "ED"},
+	}
+
+	for input, expected := range testData {
+		p := &testProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+}
+
+// Tests if code spans are reported as fragments to Processors that are not
+// CodeProcessors.
+func TestParseCodeAsFragments(t *testing.T) {
+	p := &testProcessor{}
+	Parse("Call `Parse(*doc*)`", struct{ Processor }{p})
+	assert.Equal(t, p.res, []string{"SD", "SP-P", "F-Call", "ST-SP", "F-Parse(*doc*)", "EP-P", "ED"})
+}
+
+// Tests parsing some code blocks.
+func TestParseCodeBlocks(t *testing.T) {
+	testData := map[string][]string{
+		// Contents are verbatim
+		"```go\nfunc  f() {\n\treturn *x*\\\n}\n```": {"SD", "CI-go", "SP-CB",
+			"F-func  f() {\n\treturn *x*\\\n}", "EP-CB", "ED"},
+		"```\n\n  a\r\n\n```\nText": {"SD", "CI-", "SP-CB", "F-\n  a\n", "EP-CB", "SP-P", "F-Text", "EP-P", "ED"},
+		"```\n```":                  {"SD", "CI-", "SP-CB", "EP-CB", "ED"},
+
+		// Info strings and fences
+		"  ```  c++ x \n    a\n b\n  ````  ": {"SD", "CI-c++ x", "SP-CB", "F-  a\nb", "EP-CB", "ED"},
+		"````\n```\n````":                    {"SD", "CI-", "SP-CB", "F-```", "EP-CB", "ED"},
+		"```\na\n``` b\n```":                 {"SD", "CI-", "SP-CB", "F-a\n``` b", "EP-CB", "ED"},
+
+		// Unclosed code blocks go until the end of the document
+		"```\nunclosed\n\n# still code": {"SD", "CI-", "SP-CB", "F-unclosed\n\n# still code", "EP-CB", "ED"},
+
+		// Not code blocks
+		"``` a`b\n```":           {"SD", "SP-P", "C-a`b", "EP-P", "ED"},
+		"``\nx\n``":              {"SD", "SP-P", "C-x", "EP-P", "ED"},
+		"Text\n```\ncode\n```":   {"SD", "SP-P", "F-Text", "ST-SP", "C-code", "EP-P", "ED"},
+		"+ Item\n```\ncode\n```": {"SD", "SP-UL", "F-Item", "ST-SP", "C-code", "EP-UL", "ED"},
+
+		// Code blocks end lists
+		"+ Item\n\n    ```\n    code\n    ```": {"SD", "SP-UL", "F-Item", "EP-UL", "CI-", "SP-CB", "F-code", "EP-CB", "ED"},
+	}
+
+	for input, expected := range testData {
+		p := &testProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+
+	// Processors that are not CodeProcessors don't get the info strings
+	p := &testProcessor{}
+	Parse("```go\nx\n```", struct{ Processor }{p})
+	assert.Equal(t, p.res, []string{"SD", "SP-CB", "F-x", "EP-CB", "ED"})
+}
+
+// Tests parsing some block quotes.
+func TestParseQuotes(t *testing.T) {
+	testData := map[string][]string{
+		"> Quoted":           {"SD", "SQ", "SP-P", "F-Quoted", "EP-P", "EQ", "ED"},
+		">Quoted\n  >  text": {"SD", "SQ", "SP-P", "F-Quoted", "ST-SP", "F-text", "EP-P", "EQ", "ED"},
+		">":                  {"SD", "SQ", "EQ", "ED"},
+
+		// Any paragraph type within quotes
+		"> # Title\n>\n> Text\n>\n> + Item\n> + Item": {"SD", "SQ", "SP-H1", "F-Title", "EP-H1", "SP-P", "F-Text", "EP-P",
+			"SP-UL", "F-Item", "EP-UL", "SP-UL", "F-Item", "EP-UL", "EQ", "ED"},
+		"> ```\n> *x*\n>\n> ```": {"SD", "SQ", "CI-", "SP-CB", "F-*x*\n", "EP-CB", "EQ", "ED"},
+
+		// Nested quotes
+		"> > Nested\n>\n> Outer": {"SD", "SQ", "SQ", "SP-P", "F-Nested", "EP-P", "EQ", "SP-P", "F-Outer", "EP-P", "EQ", "ED"},
+		"> > > Deep":             {"SD", "SQ", "SQ", "SQ", "SP-P", "F-Deep", "EP-P", "EQ", "EQ", "EQ", "ED"},
+		"> Outer\n> > not nested": {"SD", "SQ", "SP-P", "F-Outer", "ST-SP", "F->", "ST-SP", "F-not", "ST-SP", "F-nested",
+			"EP-P", "EQ", "ED"},
+
+		// Lazy continuation lines
+		"> Lazy\ntext":       {"SD", "SQ", "SP-P", "F-Lazy", "ST-SP", "F-text", "EP-P", "EQ", "ED"},
+		"> > Lazy\ntext":     {"SD", "SQ", "SQ", "SP-P", "F-Lazy", "ST-SP", "F-text", "EP-P", "EQ", "EQ", "ED"},
+		"> + Item\n  text":   {"SD", "SQ", "SP-UL", "F-Item", "ST-SP", "F-text", "EP-UL", "EQ", "ED"},
+		"> Not lazy\n+ Item": {"SD", "SQ", "SP-P", "F-Not", "ST-SP", "F-lazy", "EP-P", "EQ", "SP-UL", "F-Item", "EP-UL", "ED"},
+		"> Not lazy\n```\n```": {"SD", "SQ", "SP-P", "F-Not", "ST-SP", "F-lazy", "EP-P", "EQ", "CI-", "SP-CB", "EP-CB",
+			"ED"},
+		"> ```\n> code\nnot lazy": {"SD", "SQ", "CI-", "SP-CB", "F-code", "EP-CB", "EQ", "SP-P", "F-not", "ST-SP",
+			"F-lazy", "EP-P", "ED"},
+		"> Quote\n>\nNot lazy": {"SD", "SQ", "SP-P", "F-Quote", "EP-P", "EQ", "SP-P", "F-Not", "ST-SP", "F-lazy", "EP-P",
+			"ED"},
+
+		// Blank lines end quotes
+		"> One\n\n> Two": {"SD", "SQ", "SP-P", "F-One", "EP-P", "EQ", "SQ", "SP-P", "F-Two", "EP-P", "EQ", "ED"},
+
+		// Not quotes
+		"Text\n> more": {"SD", "SP-P", "F-Text", "ST-SP", "F->", "ST-SP", "F-more", "EP-P", "ED"},
+		"\\> Text":     {"SD", "SP-P", "F->", "ST-SP", "F-Text", "EP-P", "ED"},
+	}
+
+	for input, expected := range testData {
+		p := &testProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+
+	// Quotes end lists, and lists within quotes end with them
+	p := &listProcessor{}
+	Parse("+ Item\n\n> + Quoted\n\n+ Item", p)
+	assert.Equal(t, p.res, []string{"SD", "LS-1T", "IS-1T", "SP-UL", "F-Item", "EP-UL", "IE-1T", "LE-1T",
+		"SQ", "LS-1T", "IS-1T", "SP-UL", "F-Quoted", "EP-UL", "IE-1T", "LE-1T", "EQ",
+		"LS-1T", "IS-1T", "SP-UL", "F-Item", "EP-UL", "IE-1T", "LE-1T", "ED"})
+
+	// Processors that are not QuoteProcessors get just the paragraphs
+	p = &listProcessor{}
+	Parse("> > Nested\n>\n> Outer", struct{ Processor }{p})
+	assert.Equal(t, p.res, []string{"SD", "SP-P", "F-Nested", "EP-P", "SP-P", "F-Outer", "EP-P", "ED"})
+}
+
+// Tests parsing images.
+func TestParseImages(t *testing.T) {
+	testData := map[string][]string{
+		"![A cat](cat.png)":    {"SD", "SP-P", "IM-cat.png-A cat", "EP-P", "ED"},
+		"See![cat](c.png)here": {"SD", "SP-P", "F-See", "IM-c.png-cat", "F-here", "EP-P", "ED"},
+		"![](c.png)":           {"SD", "SP-P", "IM-c.png-", "EP-P", "ED"},
+		"![a](x\\)y\\))":       {"SD", "SP-P", "IM-x)y)-a", "EP-P", "ED"},
+
+		// Alternative texts are plain text
+		"![*A* **big**\n  cat](c.png) *x*": {"SD", "SP-P", "IM-c.png-A big cat", "ST-SP", "TS-EM", "F-x", "TS-RE",
+			"EP-P", "ED"},
+		"![*Open](c.png) end":       {"SD", "SP-P", "IM-c.png-Open", "ST-SP", "F-end", "EP-P", "ED"},
+		"![\\*a\\] `b]  c`](c.png)": {"SD", "SP-P", "IM-c.png-*a] b] c", "EP-P", "ED"},
+		"![a\\\nb\\ c](c.png)":      {"SD", "SP-P", "IM-c.png-a b c", "EP-P", "ED"},
+
+		// Images within links
+		"[a ![b](c)](d)":              {"SD", "SP-P", "SL-d", "F-a", "ST-SP", "IM-c-b", "EL", "EP-P", "ED"},
+		"[![badge](b.svg)](http://x)": {"SD", "SP-P", "SL-http://x", "IM-b.svg-badge", "EL", "EP-P", "ED"},
+		"[![a] b](c)":                 {"SD", "SP-P", "F-[![a]", "ST-SP", "F-b](c)", "EP-P", "ED"},
+		"[![a](b) c":                  {"SD", "SP-P", "F-[", "IM-b-a", "ST-SP", "F-c", "EP-P", "ED"},
+
+		// Not images
+		"!\\[a](b)":   {"SD", "SP-P", "F-![a](b)", "EP-P", "ED"},
+		"![a] (b)":    {"SD", "SP-P", "F-![a]", "ST-SP", "F-(b)", "EP-P", "ED"},
+		"Wow! [a](b)": {"SD", "SP-P", "F-Wow!", "ST-SP", "SL-b", "F-a", "EL", "EP-P", "ED"},
+	}
+
+	for input, expected := range testData {
+		p := &testProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+
+	// Processors that are not ImageProcessors get the alternative text
+	p := &testProcessor{}
+	Parse("A ![*big* cat](c.png)![](d.png)", struct{ Processor }{p})
+	assert.Equal(t, p.res, []string{"SD", "SP-P", "F-A", "ST-SP", "F-big cat", "EP-P", "ED"})
+}
+
 // Tests parsing some multi-paragraph inputs.
 func TestParseMultipleParagraphs(t *testing.T) {
 	testData := map[string][]string{
@@ -266,6 +609,9 @@ func TestParseMultipleParagraphs(t *testing.T) {
 			"SP-UL", "F-Ïtem", "EP-UL",
 			"SP-UL", "F-Another", "ST-SP", "F-one", "EP-UL",
 			"ED"},
+
+		// Trailing spaces before a blank line don't join paragraphs
+		"foo  \n\nbar": {"SD", "SP-P", "F-foo", "EP-P", "SP-P", "F-bar", "EP-P", "ED"},
 	}
 
 	for input, expected := range testData {
@@ -275,6 +621,148 @@ func TestParseMultipleParagraphs(t *testing.T) {
 	}
 }
 
+// Tests parsing lists with a ListProcessor.
+func TestParseLists(t *testing.T) {
+	testData := map[string][]string{
+		"+ One": {"SD", "LS-1T", "IS-1T", "SP-UL", "F-One", "EP-UL", "IE-1T", "LE-1T", "ED"},
+
+		"+ One\n\n  + Two\n\nText\n\n   + Three": {"SD",
+			"LS-1", "IS-1", "SP-UL", "F-One", "EP-UL", "IE-1",
+			"IS-1", "SP-UL", "F-Two", "EP-UL", "IE-1", "LE-1",
+			"SP-P", "F-Text", "EP-P",
+			"LS-1T", "IS-1T", "SP-UL", "F-Three", "EP-UL", "IE-1T", "LE-1T",
+			"ED"},
+
+		"+ 1\n\n    + 1.1\n\n\t+ 1.2\n\n\t\t+ 1.2.1\n\n  + 2\n\n        + 2.1": {"SD",
+			"LS-1", "IS-1", "SP-UL", "F-1", "EP-UL",
+			/**/ "LS-2", "IS-2", "SP-UL", "F-1.1", "EP-UL", "IE-2",
+			/**/ "IS-2", "SP-UL", "F-1.2", "EP-UL",
+			/*    */ "LS-3T", "IS-3T", "SP-UL", "F-1.2.1", "EP-UL", "IE-3T", "LE-3T",
+			/**/ "IE-2", "LE-2",
+			"IE-1",
+			"IS-1", "SP-UL", "F-2", "EP-UL",
+			/**/ "LS-2T", "IS-2T", "SP-UL", "F-2.1", "EP-UL", "IE-2T", "LE-2T",
+			"IE-1", "LE-1",
+			"ED"},
+
+		// Going back to a shallower indentation than the nested list's
+		"\t+ 1\n\n\t\t+ 1.1\n\n\t  + 2\n\n+ 3": {"SD",
+			"LS-1", "IS-1", "SP-UL", "F-1", "EP-UL",
+			/**/ "LS-2T", "IS-2T", "SP-UL", "F-1.1", "EP-UL", "IE-2T", "LE-2T",
+			"IE-1",
+			"IS-1", "SP-UL", "F-2", "EP-UL", "IE-1",
+			"IS-1", "SP-UL", "F-3", "EP-UL", "IE-1", "LE-1",
+			"ED"},
+
+		// Lists end before headings
+		"+ One\n\n    + Two\n\n# Three": {"SD",
+			"LS-1T", "IS-1T", "SP-UL", "F-One", "EP-UL",
+			/**/ "LS-2T", "IS-2T", "SP-UL", "F-Two", "EP-UL", "IE-2T", "LE-2T",
+			"IE-1T", "LE-1T",
+			"SP-H1", "F-Three", "EP-H1",
+			"ED"},
+	}
+
+	for input, expected := range testData {
+		p := &listProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+}
+
+// Tests parsing ordered lists.
+func TestParseOrderedLists(t *testing.T) {
+	testData := map[string][]string{
+		"1. One\n\n1. Two": {"SD",
+			"LS-1#1", "IS-1#1", "SP-OL", "F-One", "EP-OL", "IE-1#1",
+			"IS-1#1", "SP-OL", "F-Two", "EP-OL", "IE-1#1", "LE-1#1",
+			"ED"},
+
+		"3.\tThree\n\n9. Four\n\n+ Bullet\n\n    0010. Ten": {"SD",
+			"LS-1#3", "IS-1#3", "SP-OL", "F-Three", "EP-OL", "IE-1#3",
+			"IS-1#3", "SP-OL", "F-Four", "EP-OL", "IE-1#3", "LE-1#3",
+			"LS-1T", "IS-1T", "SP-UL", "F-Bullet", "EP-UL",
+			/**/ "LS-2#10T", "IS-2#10T", "SP-OL", "F-Ten", "EP-OL", "IE-2#10T", "LE-2#10T",
+			"IE-1T", "LE-1T",
+			"ED"},
+
+		// Not list items
+		"1.One":          {"SD", "SP-P", "F-1.One", "EP-P", "ED"},
+		"1\\. One":       {"SD", "SP-P", "F-1.", "ST-SP", "F-One", "EP-P", "ED"},
+		"1) One":         {"SD", "SP-P", "F-1)", "ST-SP", "F-One", "EP-P", "ED"},
+		"x1. One":        {"SD", "SP-P", "F-x1.", "ST-SP", "F-One", "EP-P", "ED"},
+		"1234567890. Hi": {"SD", "SP-P", "F-1234567890.", "ST-SP", "F-Hi", "EP-P", "ED"},
+	}
+
+	for input, expected := range testData {
+		p := &listProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+}
+
+// Tests parsing tight lists, whose items are not separated by blank lines.
+func TestParseTightLists(t *testing.T) {
+	testData := map[string][]string{
+		"+ One\n+ Two": {"SD",
+			"LS-1T", "IS-1T", "SP-UL", "F-One", "EP-UL", "IE-1T",
+			"IS-1T", "SP-UL", "F-Two", "EP-UL", "IE-1T", "LE-1T",
+			"ED"},
+
+		// Trailing spaces and continuation lines
+		"+ One  \n  more\n+ Two  \n": {"SD",
+			"LS-1T", "IS-1T", "SP-UL", "F-One", "ST-SP", "F-more", "EP-UL", "IE-1T",
+			"IS-1T", "SP-UL", "F-Two", "EP-UL", "IE-1T", "LE-1T",
+			"ED"},
+
+		// Nested tight list within a loose list
+		"1. One\n    + 1.1\n    + 1.2\n\n2. Two": {"SD",
+			"LS-1#1", "IS-1#1", "SP-OL", "F-One", "EP-OL",
+			/**/ "LS-2T", "IS-2T", "SP-UL", "F-1.1", "EP-UL", "IE-2T",
+			/**/ "IS-2T", "SP-UL", "F-1.2", "EP-UL", "IE-2T", "LE-2T",
+			"IE-1#1",
+			"IS-1#1", "SP-OL", "F-Two", "EP-OL", "IE-1#1", "LE-1#1",
+			"ED"},
+
+		// A blank line between any two items makes the list loose
+		"+ One\n+ Two\n\n+ Three": {"SD",
+			"LS-1", "IS-1", "SP-UL", "F-One", "EP-UL", "IE-1",
+			"IS-1", "SP-UL", "F-Two", "EP-UL", "IE-1",
+			"IS-1", "SP-UL", "F-Three", "EP-UL", "IE-1", "LE-1",
+			"ED"},
+
+		// Changing the list kind without a blank line starts a new list
+		"+ One\n1. Two": {"SD",
+			"LS-1T", "IS-1T", "SP-UL", "F-One", "EP-UL", "IE-1T", "LE-1T",
+			"LS-1#1T", "IS-1#1T", "SP-OL", "F-Two", "EP-OL", "IE-1#1T", "LE-1#1T",
+			"ED"},
+
+		// Links don't cross list items, but a hard line break makes the next
+		// line part of the same item
+		"+ [One\n+ Two](x)\n+ Three\\\n+ Four": {"SD",
+			"LS-1T", "IS-1T", "SP-UL", "F-[One", "EP-UL", "IE-1T",
+			"IS-1T", "SP-UL", "F-Two](x)", "EP-UL", "IE-1T",
+			"IS-1T", "SP-UL", "F-Three", "ST-NL", "F-+", "ST-SP", "F-Four", "EP-UL", "IE-1T", "LE-1T",
+			"ED"},
+
+		// A line after a hard line break is not an item, so it doesn't hide a
+		// blank line between items
+		"+ a\\\n1. b\n\n+ c": {"SD",
+			"LS-1", "IS-1", "SP-UL", "F-a", "ST-NL", "F-1.", "ST-SP", "F-b", "EP-UL", "IE-1",
+			"IS-1", "SP-UL", "F-c", "EP-UL", "IE-1", "LE-1",
+			"ED"},
+
+		// Outside of lists, a line starting like a list item is just text
+		"Text\n+ More": {"SD", "SP-P", "F-Text", "ST-SP", "F-+", "ST-SP", "F-More", "EP-P", "ED"},
+	}
+
+	for input, expected := range testData {
+		p := &listProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+}
+
 // Tests parsing different kinds of newlines.
 func TestParseNewLines(t *testing.T) {
 	expectedResult := []string{"SD", "SP-P", "F-One", "ST-SP", "F-single", "ST-SP", "F-paragraph.", "EP-P", "ED"}
@@ -299,9 +787,10 @@ func TestParseNewLines(t *testing.T) {
 // Tests inputs with hard spaces and hard line breaks.
 func TestParseHardSpacing(t *testing.T) {
 	testData := map[string][]string{
-		"«\\ Où\\ ?\\ »": {"SD", "SP-P", "F-« Où ? »", "EP-P", "ED"},
-		"blah\\ ":        {"SD", "SP-P", "F-blah ", "EP-P", "ED"},
-		" blah\\  ":      {"SD", "SP-P", "F-blah ", "EP-P", "ED"},
+		"«\\ Où\\ ?\\ »": {"SD", "SP-P", "F-«", "ST-NB", "F-Où", "ST-NB", "F-?", "ST-NB", "F-»", "EP-P", "ED"},
+		"blah\\ ":        {"SD", "SP-P", "F-blah", "ST-NB", "EP-P", "ED"},
+		" blah\\  ":      {"SD", "SP-P", "F-blah", "ST-NB", "EP-P", "ED"},
+		"a\\\tb":         {"SD", "SP-P", "F-a", "ST-NB", "F-b", "EP-P", "ED"},
 
 		"line\\\nbreak":   {"SD", "SP-P", "F-line", "ST-NL", "F-break", "EP-P", "ED"},
 		"line\\\rbreak":   {"SD", "SP-P", "F-line", "ST-NL", "F-break", "EP-P", "ED"},
@@ -309,8 +798,9 @@ func TestParseHardSpacing(t *testing.T) {
 		"line\\\n\rbreak": {"SD", "SP-P", "F-line", "ST-NL", "F-break", "EP-P", "ED"},
 
 		"here  \\\n there":   {"SD", "SP-P", "F-here", "ST-NL", "F-there", "EP-P", "ED"},
-		"here  \\\n\\ there": {"SD", "SP-P", "F-here", "ST-NL", "F- there", "EP-P", "ED"},
-		"here \\ \\\n there": {"SD", "SP-P", "F-here", "ST-SP", "F- ", "ST-NL", "F-there", "EP-P", "ED"},
+		"here\n\\\nthere":    {"SD", "SP-P", "F-here", "ST-NL", "F-there", "EP-P", "ED"},
+		"here  \\\n\\ there": {"SD", "SP-P", "F-here", "ST-NL", "ST-NB", "F-there", "EP-P", "ED"},
+		"here \\ \\\n there": {"SD", "SP-P", "F-here", "ST-SP", "ST-NB", "ST-NL", "F-there", "EP-P", "ED"},
 	}
 
 	for input, expected := range testData {
@@ -366,19 +856,6 @@ func TestParseRealDocument(t *testing.T) {
 // Benchmark
 //
 
-// noopProcessor is a Markydown processor that doesn't do anything.
-type noopProcessor struct{}
-
-func (p *noopProcessor) StartDocument()                  {}
-func (p *noopProcessor) EndDocument()                    {}
-func (p *noopProcessor) StartParagraph(parType ParType)  {}
-func (p *noopProcessor) EndParagraph(parType ParType)    {}
-func (p *noopProcessor) Fragment(text string)            {}
-func (p *noopProcessor) SpecialToken(token SpecialToken) {}
-func (p *noopProcessor) ChangeTextStyle(style TextStyle) {}
-func (p *noopProcessor) StartLink(target string)         {}
-func (p *noopProcessor) EndLink()                        {}
-
 // Benchmarks the Markydown parser.
 func BenchmarkParser(b *testing.B) {
 	const input = `
@@ -0,0 +1,114 @@
+package markydown
+
+import "sort"
+
+// Span describes a region of the input document.
+type Span struct {
+	// StartOffset is the byte offset, counting from the start of the document,
+	// where the span starts.
+	StartOffset int
+
+	// EndOffset is the byte offset, counting from the start of the document,
+	// right after the end of the span. Spans can be empty, in which case
+	// EndOffset == StartOffset.
+	EndOffset int
+
+	// Line is the line number where the span starts. The first line is line 1.
+	Line int
+
+	// Column is the column number where the span starts, in bytes. The first
+	// column is column 1.
+	Column int
+}
+
+// PositionedProcessor is a Processor that wants to know where in the input
+// document each of the things reported to it came from.
+//
+// If the Processor passed to the parser implements this interface, SourceSpan
+// is called right before each call to one of the other Processor methods,
+// telling the span of input that originated the upcoming call. More
+// specifically, the spans are:
+//
+//   - StartDocument and EndDocument: empty spans at the start and end of the
+//     document.
+//   - StartParagraph: the marker that defines the paragraph type (like `## `
+//     or `+ `, or the whole opening fence line of code blocks). This is an
+//     empty span for regular text paragraphs.
+//   - EndParagraph: the whole paragraph, from the start of its marker to the
+//     end of its last element.
+//   - Fragment: the text, including any escape characters. For code blocks,
+//     all the lines between the fences.
+//   - SpecialToken: the spaces or the escaped new line represented by the
+//     token.
+//   - ChangeTextStyle: the emphasis marker (`*` or `**`). Styles left open
+//     at the end of a paragraph are closed with an empty span at the end of
+//     its last element.
+//   - StartLink and EndLink: the `[` and the `](target)`, respectively.
+//   - Code (for CodeProcessors, or Fragment otherwise, for code spans): the
+//     whole code span, including its backticks.
+//   - CodeBlockInfo (for CodeProcessors): the info string, or an empty span
+//     right after the backticks of the opening fence if there is none.
+//   - StartList and StartListItem (for ListProcessors): an empty span at the
+//     start of the list item.
+//   - EndList and EndListItem (for ListProcessors): an empty span at the end
+//     of the last element of the list item.
+//   - StartQuote (for QuoteProcessors): the first `>` of the block quote.
+//   - EndQuote (for QuoteProcessors): the whole block quote, from its first
+//     `>` to the end of its last element.
+//   - Image (for ImageProcessors, or Fragment otherwise, for images): the
+//     whole image, from its `!` to its `)`.
+type PositionedProcessor interface {
+	Processor
+
+	// SourceSpan is called right before each call to other Processor method,
+	// passing the input span corresponding to that call.
+	SourceSpan(span Span)
+}
+
+// lineIndex knows where each line of a document starts, and therefore can
+// convert byte offsets to line and column numbers.
+//
+// Documents can be added to the index bit by bit, and the index can forget
+// about lines that are not interesting anymore. That's what we need when
+// parsing from an io.Reader.
+type lineIndex struct {
+	starts    []int // Offsets where each line starts
+	firstLine int   // Number of lines forgotten, i.e., line number of starts[0] minus one
+}
+
+// add adds some text, found at a given offset of the document, to the index.
+//
+// A new line is anything accepted as new line by the parser: LF, CR, CRLF or
+// LFCR. The text added must not end in the middle of a CRLF or LFCR pair.
+func (li *lineIndex) add(text string, offset int) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '\n' && c != '\r' {
+			continue
+		}
+		if i+1 < len(text) && isNewLine(rune(text[i+1])) && text[i+1] != c {
+			i++
+		}
+		li.starts = append(li.starts, offset+i+1)
+	}
+}
+
+// forget makes the index forget about the lines before the one containing a
+// given offset.
+func (li *lineIndex) forget(offset int) {
+	i := li.lineAt(offset)
+	li.starts = append(li.starts[:0], li.starts[i:]...)
+	li.firstLine += i
+}
+
+// position returns the line and column numbers (both starting at one) of a
+// given byte offset.
+func (li *lineIndex) position(offset int) (line, column int) {
+	i := li.lineAt(offset)
+	return li.firstLine + i + 1, offset - li.starts[i] + 1
+}
+
+// lineAt returns the index into starts of the line containing a given offset.
+func (li *lineIndex) lineAt(offset int) int {
+	return sort.Search(len(li.starts), func(i int) bool { return li.starts[i] > offset }) - 1
+}
@@ -0,0 +1,148 @@
+package markydown
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// positionProcessor is a testProcessor that also records the source spans it
+// receives.
+type positionProcessor struct {
+	testProcessor
+}
+
+func (p *positionProcessor) SourceSpan(span Span) {
+	p.res = append(p.res, spanToString(span))
+}
+
+// spanToString converts a Span to a string like "1:3[2,4]" (line, column and
+// offsets).
+func spanToString(span Span) string {
+	return fmt.Sprintf("%d:%d[%d,%d]", span.Line, span.Column, span.StartOffset, span.EndOffset)
+}
+
+// Tests the source spans reported to PositionedProcessors.
+func TestParsePositions(t *testing.T) {
+	testData := map[string][]string{
+		"": {"1:1[0,0]", "SD", "1:1[0,0]", "ED"},
+
+		"  Hi there.\n": {
+			"1:1[0,0]", "SD",
+			"1:3[2,2]", "SP-P",
+			"1:3[2,4]", "F-Hi",
+			"1:5[4,5]", "ST-SP",
+			"1:6[5,11]", "F-there.",
+			"1:3[2,11]", "EP-P",
+			"2:1[12,12]", "ED"},
+
+		"## *T\\*t*\r\n\r\n+ [a](b)\\\nc": {
+			"1:1[0,0]", "SD",
+			"1:1[0,3]", "SP-H2",
+			"1:4[3,4]", "TS-EM",
+			"1:5[4,8]", "F-T*t",
+			"1:9[8,9]", "TS-RE",
+			"1:1[0,9]", "EP-H2",
+			"3:1[13,15]", "SP-UL",
+			"3:3[15,16]", "SL-b",
+			"3:4[16,17]", "F-a",
+			"3:5[17,21]", "EL",
+			"3:9[21,23]", "ST-NL",
+			"4:1[23,24]", "F-c",
+			"3:1[13,24]", "EP-UL",
+			"4:2[24,24]", "ED"},
+
+		"```go\na\n```\n": {
+			"1:1[0,0]", "SD",
+			"1:4[3,5]", "CI-go",
+			"1:1[0,5]", "SP-CB",
+			"2:1[6,7]", "F-a",
+			"1:1[0,11]", "EP-CB",
+			"4:1[12,12]", "ED"},
+
+		"a `b`": {
+			"1:1[0,0]", "SD",
+			"1:1[0,0]", "SP-P",
+			"1:1[0,1]", "F-a",
+			"1:2[1,2]", "ST-SP",
+			"1:3[2,5]", "C-b",
+			"1:1[0,5]", "EP-P",
+			"1:6[5,5]", "ED"},
+
+		"1\\ m": {
+			"1:1[0,0]", "SD",
+			"1:1[0,0]", "SP-P",
+			"1:1[0,1]", "F-1",
+			"1:2[1,3]", "ST-NB",
+			"1:4[3,4]", "F-m",
+			"1:1[0,4]", "EP-P",
+			"1:5[4,4]", "ED"},
+
+		// Images span from the `!` to the `)`
+		"a ![b](c)": {
+			"1:1[0,0]", "SD",
+			"1:1[0,0]", "SP-P",
+			"1:1[0,1]", "F-a",
+			"1:2[1,2]", "ST-SP",
+			"1:3[2,9]", "IM-c-b",
+			"1:1[0,9]", "EP-P",
+			"1:10[9,9]", "ED"},
+
+		// Quotes map positions back to the outer document
+		"> a\n>\n> > b\nc": {
+			"1:1[0,0]", "SD",
+			"1:1[0,1]", "SQ",
+			"1:3[2,2]", "SP-P",
+			"1:3[2,3]", "F-a",
+			"1:3[2,3]", "EP-P",
+			"3:3[8,9]", "SQ",
+			"3:5[10,10]", "SP-P",
+			"3:5[10,11]", "F-b",
+			"3:6[11,12]", "ST-SP",
+			"4:1[12,13]", "F-c",
+			"3:5[10,13]", "EP-P",
+			"3:3[8,13]", "EQ",
+			"1:1[0,13]", "EQ",
+			"4:2[13,13]", "ED"},
+
+		// Styles left open are closed with an empty span
+		"**Hi  \n": {
+			"1:1[0,0]", "SD",
+			"1:1[0,0]", "SP-P",
+			"1:1[0,2]", "TS-ST",
+			"1:3[2,4]", "F-Hi",
+			"1:5[4,4]", "TS-RE",
+			"1:1[0,4]", "EP-P",
+			"2:1[7,7]", "ED"},
+	}
+
+	for input, expected := range testData {
+		p := &positionProcessor{}
+		Parse(input, p)
+		assert.Equal(t, p.res, expected)
+	}
+}
+
+// Tests converting offsets to lines and columns.
+func TestLineIndex(t *testing.T) {
+	li := &lineIndex{starts: []int{0}}
+	li.add("ab\ncd\r\n\n\rx\r\ry", 0)
+
+	testData := map[int][2]int{
+		0:  {1, 1},
+		2:  {1, 3},
+		3:  {2, 1},
+		5:  {2, 3},
+		7:  {3, 1},
+		9:  {4, 1},
+		10: {4, 2},
+		11: {5, 1},
+		12: {6, 1},
+	}
+
+	for offset, expected := range testData {
+		line, column := li.position(offset)
+		assert.Equal(t, [2]int{line, column}, expected)
+	}
+}
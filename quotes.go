@@ -0,0 +1,195 @@
+package markydown
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// QuoteProcessor is a Processor that wants to know about block quotes.
+//
+// A block quote is a sequence of lines starting with `>` (optionally followed
+// by a space). What is left after removing these markers is parsed as a
+// document of its own, so it can contain headings, paragraphs, lists, code
+// blocks and even other (nested) block quotes. A line without the `>` is
+// still part of the quote if it just continues a paragraph from the line
+// before it (a "lazy" continuation line), unless it starts a list item or a
+// code block. A blank line without the `>` ends the quote.
+//
+// Every Processor is told about the contents of quotes as regular paragraphs.
+// If the Processor passed to the parser implements this interface, they are
+// additionally wrapped in calls telling where quotes start and end. For
+// example, this Markydown:
+//
+//	> # Title
+//	>
+//	> > Nested
+//	lazy
+//	>
+//	> + Item
+//
+// generates this sequence of calls (paragraph contents omitted):
+//
+//	StartQuote()
+//	StartParagraph(ParTypeHeading1)
+//	EndParagraph(ParTypeHeading1)
+//	StartQuote()
+//	StartParagraph(ParTypeText)
+//	EndParagraph(ParTypeText)
+//	EndQuote()
+//	StartParagraph(ParTypeBulletedList)
+//	EndParagraph(ParTypeBulletedList)
+//	EndQuote()
+//
+// (Plus the list calls, for ListProcessors.) Like other paragraphs, a nested
+// quote must start after a blank line (or at the start of the quote).
+type QuoteProcessor interface {
+	Processor
+
+	// StartQuote is called when a block quote starts.
+	StartQuote()
+
+	// EndQuote is called when a block quote ends.
+	EndQuote()
+}
+
+// quoteText is the text of a block quote (without the quote markers), which
+// is parsed by a parser of its own.
+type quoteText struct {
+	outer   *parser       // The parser of the document the quote is in
+	offsets []quoteOffset // Where each line of the text comes from, sorted by offset
+}
+
+// quoteOffset maps the offset where a line starts in the text of a block quote
+// to the offset where it starts in the outer document.
+type quoteOffset struct {
+	inner int
+	outer int
+}
+
+// outerOffset converts an offset into the text of a block quote to an offset
+// into the outer document.
+func (q *quoteText) outerOffset(offset int) int {
+	i := sort.Search(len(q.offsets), func(i int) bool { return q.offsets[i].inner > offset }) - 1
+	return q.offsets[i].outer + offset - q.offsets[i].inner
+}
+
+// parseQuote parses a block quote. Returns true if the parsing succeeded or
+// false otherwise (in which case no input is consumed).
+func (p *parser) parseQuote() bool {
+	if r, _ := utf8.DecodeRuneInString(p.input); !isQuoteMarker(r) {
+		return false
+	}
+
+	var b strings.Builder
+	var offsets []quoteOffset
+	pos := 0
+	lazy := false      // Can the next line be a lazy continuation line?
+	fenceStart := true // Can the next line open a code block?
+	fence := 0         // Backticks in the fence of the code block we are in, if any
+
+	for {
+		line, next, ok := p.lookAheadLine(pos)
+		if !ok {
+			break
+		}
+
+		contents, marked := quoteLineContents(line)
+		if !marked && (!lazy || !isLazyLine(line)) {
+			break
+		}
+
+		offsets = append(offsets, quoteOffset{
+			inner: b.Len(),
+			outer: p.offsetOf(p.input[pos:]) + len(line) - len(contents),
+		})
+		b.WriteString(contents)
+		b.WriteString(p.input[pos+len(line) : next])
+		pos = next
+
+		_, trimmed := indentationOf(contents)
+
+		switch {
+		case fence > 0:
+			if isClosingCodeFence(trimmed, fence) {
+				fence = 0
+				fenceStart = true
+			}
+			lazy = false
+
+		case trimmed == "":
+			lazy = false
+			fenceStart = true
+
+		case fenceStart:
+			fence, _ = codeFence(trimmed)
+			lazy = fence == 0
+			fenceStart = fence == 0
+
+		default:
+			lazy = true
+			fenceStart = false
+		}
+	}
+
+	p.input = p.input[pos:]
+	p.lastEnd = p.parStart + 1
+
+	if p.quoter != nil {
+		p.at(p.parStart, p.lastEnd)
+		p.quoter.StartQuote()
+	}
+
+	text := b.String()
+	q := &parser{
+		processor: p.processor,
+		textStyle: TextStyleRegular,
+		opts:      p.opts,
+		coder:     p.coder,
+		lister:    p.lister,
+		quoter:    p.quoter,
+		imager:    p.imager,
+		quote:     &quoteText{outer: p, offsets: offsets},
+		buf:       text,
+		input:     text,
+	}
+	q.parseBlocks()
+
+	if p.quoter != nil {
+		p.at(p.parStart, p.lastEnd)
+		p.quoter.EndQuote()
+	}
+
+	return true
+}
+
+// quoteLineContents returns the contents of a line of a block quote, that is,
+// what is left after its quote marker (and the optional space after it).
+// Returns false if the line has no quote marker.
+func quoteLineContents(line string) (string, bool) {
+	contents := strings.TrimLeftFunc(line, isHorizontalSpace)
+	r, w := utf8.DecodeRuneInString(contents)
+	if !isQuoteMarker(r) {
+		return line, false
+	}
+
+	contents = contents[w:]
+	if r, w = utf8.DecodeRuneInString(contents); isHorizontalSpace(r) {
+		contents = contents[w:]
+	}
+
+	return contents, true
+}
+
+// isLazyLine checks if a given line, which follows a line of paragraph text
+// in a block quote, can be a lazy continuation line of the quote.
+func isLazyLine(line string) bool {
+	_, contents := indentationOf(line)
+	if contents == "" {
+		return false
+	}
+
+	_, _, markerLen := listMarker(contents)
+	fence, _ := codeFence(contents)
+	return markerLen == 0 && fence == 0
+}
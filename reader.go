@@ -0,0 +1,133 @@
+package markydown
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ParseReader parses a Markydown document read from an io.Reader and lets the
+// passed Processor do its work as the document is parsed.
+//
+// This does the same as Parse, and calls the Processor methods in the very same
+// sequence Parse would, but the input is read and parsed one paragraph at a
+// time, so that the whole document doesn't need to be in memory. The exceptions
+// are lists and block quotes: to tell if a list is tight, the parser must read
+// ahead up to its end (or up to the first blank line between its items), so
+// tight lists are kept in memory as a whole; and each block quote is read as a
+// whole before its contents are parsed.
+//
+// If reading from r fails, parsing stops as if the end of the document was
+// reached (therefore EndDocument is still called) and the error is returned.
+func ParseReader(r io.Reader, processor Processor, options ...Option) error {
+	p := newParser(processor, options)
+	p.reader = bufio.NewReader(r)
+
+	p.parseDocument()
+
+	return p.err
+}
+
+// readParagraph makes sure the whole paragraph starting at the current input
+// position is in the input buffer, along with the blank line that terminates
+// it. (The paragraph may end earlier, but it will never extend past a blank
+// line.)
+func (p *parser) readParagraph() {
+	for p.breakOffset <= p.offset() {
+		if !p.fill() {
+			return
+		}
+	}
+}
+
+// fill reads one more line of input into the input buffer. Returns a Boolean
+// indicating if something could be read. (Reading fails if we are not reading
+// from an io.Reader, if we reached the end of input, or if some error
+// happened, now or before.)
+//
+// Input already consumed is eventually dropped from the buffer (except for the
+// start of the current line, which we need to compute indentation), so this
+// must not be called in the middle of a paragraph (when we may be holding
+// references to it).
+func (p *parser) fill() bool {
+	if p.reader == nil || p.err != nil {
+		return false
+	}
+
+	line := p.readLine()
+	if len(line) == 0 {
+		p.reader = nil
+		return false
+	}
+
+	consumed := p.buf[:len(p.buf)-len(p.input)]
+	kept := consumed[strings.LastIndexAny(consumed, "\n\r")+1:]
+
+	// Dropping consumed input means copying what is left to a new buffer, so
+	// we only do it when there is at least as much to drop as to copy. Lines
+	// are appended in place otherwise, so reading long paragraphs stays linear
+	if len(consumed)-len(kept) >= len(kept)+len(p.input) {
+		p.bufStart = p.offset() - len(kept)
+		p.bufBuilder.Reset()
+		p.bufBuilder.WriteString(kept)
+		p.bufBuilder.WriteString(p.input)
+	}
+
+	inputLen := len(p.input) + len(line)
+	p.bufBuilder.WriteString(line)
+	p.buf = p.bufBuilder.String()
+	p.input = p.buf[len(p.buf)-inputLen:]
+
+	if p.lines != nil {
+		// Lists still open are closed with spans at the end of the last
+		// reported element, so we must remember where that is
+		forget := p.bufStart
+		if p.lastEnd < forget {
+			forget = p.lastEnd
+		}
+		p.lines.forget(forget)
+		p.lines.add(line, p.bufStart+len(p.buf)-len(line))
+	}
+
+	if isBlankLine(line) {
+		p.breakOffset = p.bufStart + len(p.buf)
+	}
+
+	return true
+}
+
+// readLine reads a line from the reader, including the new line character(s)
+// that ends it. A CRLF or LFCR pair is always read as a whole. Returns an empty
+// string if the end of input was reached or an error happened. Only the first
+// error is kept.
+func (p *parser) readLine() string {
+	var line []byte
+
+	for {
+		c, err := p.reader.ReadByte()
+		if err != nil {
+			if err != io.EOF && p.err == nil {
+				p.err = err
+			}
+			return string(line)
+		}
+
+		line = append(line, c)
+
+		if isNewLine(rune(c)) {
+			next, err := p.reader.Peek(1)
+			if err == nil && isNewLine(rune(next[0])) && next[0] != c {
+				p.reader.ReadByte()
+				line = append(line, next[0])
+			}
+			return string(line)
+		}
+	}
+}
+
+// isBlankLine checks if a given line contains nothing but spaces (and the new
+// line at its end, if any).
+func isBlankLine(line string) bool {
+	line = strings.TrimLeftFunc(line, isHorizontalSpace)
+	return len(line) == 0 || isNewLine(rune(line[0]))
+}
@@ -0,0 +1,170 @@
+package markydown
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// readerTestInputs are inputs used to check if ParseReader works just like
+// Parse.
+var readerTestInputs = []string{
+	"",
+	"   \t\n\n \t\n    \t    \n\r  \r\n\t  ",
+	"one",
+	"fünf\\",
+	"\n um  \n\r dois\n\r  três  ",
+	"One\r\nsingle\n\rparagraph.\r\n\r\nAnother\rone.\r\rAnd\n\r\nmore.",
+	"here \\ \\\n there",
+	"line\\\n\nbreak",
+	"Click [here](target).",
+	"An ![*image*\n  here](x) and [a ![b](c)](d)",
+	"+ a\n\nb\n\nc",
+	"[Not\n\na](link)",
+	"[Not a](li\n  \nnk)",
+	"+ Click [here, *please*!](the*tárgeτ*)",
+	"+ 1\n\n    + 1.1\n   \n\t\t+ 1.1.1\n\n  + 2\n\nText\n\n+ 3",
+	"7. Seven\n\n    + Bullet\n\n8. Eight\n\n+ Other list",
+	"+ Later\n+ blank  \n    1. Nested\n    2. too\n\n+ makes it\n+ [loose\n+ no link](x)",
+	"foo  \n\nbar",
+	"Code:\n\n```go\n\nfunc main() {\n\n\n}\n\n````\n\n\n```\nText\n\n```\n\nunclosed\n",
+	"> Quote\r\n> > nested\n\rlazy\n>\n>\t+ [list\n> + item](x)\n\n> ```\n>\n> ```\n>\n\n>",
+	`# The  title
+
+	Paragraph one.
+	Still the *same paragraph*.
+
+	## Subtitle
+
+	+ First;
+
+	+ [Second](http://www.example.com);
+
+	+ **Third**, ok?
+
+
+
+	### Sub*sub*ti\*tle
+
+	Here \[we\] have some **more**   text\
+	with        some hard  \
+	breaks.
+
+	`,
+}
+
+// Tests if ParseReader generates the same calls as Parse.
+func TestParseReader(t *testing.T) {
+	for _, input := range readerTestInputs {
+		expected := &testProcessor{}
+		Parse(input, expected)
+
+		actual := &testProcessor{}
+		err := ParseReader(strings.NewReader(input), actual)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actual.res, expected.res)
+
+		actual = &testProcessor{}
+		err = ParseReader(iotest.OneByteReader(strings.NewReader(input)), actual)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actual.res, expected.res)
+
+		expectedList := &listProcessor{}
+		Parse(input, expectedList)
+
+		actualList := &listProcessor{}
+		err = ParseReader(iotest.OneByteReader(strings.NewReader(input)), actualList)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actualList.res, expectedList.res)
+	}
+}
+
+// Tests if ParseReader reports the same positions as Parse.
+func TestParseReaderPositions(t *testing.T) {
+	for _, input := range readerTestInputs {
+		expected := &positionProcessor{}
+		Parse(input, expected)
+
+		actual := &positionProcessor{}
+		err := ParseReader(iotest.HalfReader(strings.NewReader(input)), actual)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actual.res, expected.res)
+
+		expectedList := &positionListProcessor{}
+		Parse(input, expectedList)
+
+		actualList := &positionListProcessor{}
+		err = ParseReader(iotest.HalfReader(strings.NewReader(input)), actualList)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, actualList.res, expectedList.res)
+	}
+}
+
+// positionListProcessor is a listProcessor that also records the source spans
+// it receives.
+type positionListProcessor struct {
+	listProcessor
+}
+
+func (p *positionListProcessor) SourceSpan(span Span) {
+	p.res = append(p.res, spanToString(span))
+}
+
+// Tests if ParseReader reports read errors.
+func TestParseReaderError(t *testing.T) {
+	errRead := errors.New("read error")
+	r := iotest.DataErrReader(&errorReader{"Some text", errRead})
+
+	p := &testProcessor{}
+	err := ParseReader(r, p)
+	assert.Equal(t, err, errRead)
+	assert.Equal(t, p.res, []string{"SD", "SP-P", "F-Some", "ST-SP", "F-text", "EP-P", "ED"})
+}
+
+// Tests if ParseReader returns the first read error, and stops reading after
+// it.
+func TestParseReaderFirstError(t *testing.T) {
+	errFirst := errors.New("first error")
+	errSecond := errors.New("second error")
+	r := &chainedErrorReader{{"Some text", errFirst}, {" and more", errSecond}}
+
+	p := &testProcessor{}
+	err := ParseReader(r, p)
+	assert.Equal(t, err, errFirst)
+	assert.Equal(t, p.res, []string{"SD", "SP-P", "F-Some", "ST-SP", "F-text", "EP-P", "ED"})
+}
+
+// chainedErrorReader is an io.Reader that reads from a sequence of
+// errorReaders, moving to the next one after each error.
+type chainedErrorReader []*errorReader
+
+func (r *chainedErrorReader) Read(p []byte) (int, error) {
+	if len(*r) == 0 {
+		return 0, io.EOF
+	}
+
+	n, err := (*r)[0].Read(p)
+	if err != nil {
+		*r = (*r)[1:]
+	}
+	return n, err
+}
+
+// errorReader is an io.Reader that returns some data and then fails.
+type errorReader struct {
+	data string
+	err  error
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
@@ -0,0 +1,463 @@
+package markydown
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextRenderer is a Processor that renders a Markydown document as plain text,
+// writing the results to an io.Writer. This is intended for things like
+// terminal help screens and emails.
+//
+// Paragraphs are wrapped at Width columns: SpecialTokenSpaces are the places
+// where lines can be broken, so SpecialTokenNonBreakingSpaces (escaped spaces)
+// are never broken.
+//
+// Headings are underlined (with `=` for level 1 headings, and with `-` for the
+// others), list items are hang-indented after their bullets or numbers, and
+// link targets are shown after the link text, like in `text <target>`. Code
+// blocks are indented by four spaces, and never wrapped. Lines within block
+// quotes are prefixed with `> `, as usual in emails. Text styles are ignored.
+type TextRenderer struct {
+	// Width is the maximum line width, in characters. Words longer than this
+	// are not broken, so they may exceed it. Zero (or less) disables wrapping.
+	Width int
+
+	w         io.Writer         // Where the output goes to
+	err       error             // The first error found while writing, if any
+	ansi      *ANSICapabilities // Terminal capabilities, if rendering for an ANSIRenderer; nil otherwise
+	started   bool              // Have we written any paragraph yet?
+	segments  [][]textWord      // Words of the current paragraph, one slice per line forced by line breaks
+	word      textWord          // The word being built
+	style     TextStyle         // The current text style
+	links     []string          // Stack of targets of the links we are in
+	lists     []textList        // Stack of lists we are in
+	marker    string            // Marker (like a bullet) of the list item whose paragraph is next
+	tightItem bool              // Is the next paragraph an item of a tight list that needs no blank line before it?
+	codeBlock bool              // Are we in a code block?
+	code      string            // Contents of the current code block
+	quotes    textQuotes        // The block quotes we are in
+}
+
+// textRun is a piece of text rendered by a TextRenderer, all in the same style.
+type textRun struct {
+	text  string
+	style TextStyle
+	link  string // Target of the link the text is in, if rendered as a hyperlink
+}
+
+// textWord is a word rendered by a TextRenderer. Words are never broken across
+// lines, but may contain different styles.
+type textWord []textRun
+
+// textCodeIndent is how much (in columns) a TextRenderer indents code blocks.
+const textCodeIndent = 4
+
+// textList is a list being rendered by a TextRenderer.
+type textList struct {
+	info       ListInfo
+	items      int // Number of items started so far
+	indent     int // Indentation of the list markers
+	textIndent int // Indentation of the contents of the current item
+}
+
+// textQuotes keeps track of the block quotes a text-based renderer is in, so
+// that it can prefix the lines within them with `> `s.
+type textQuotes struct {
+	depth    int  // Number of quotes we are in
+	sepDepth int  // Number of quotes the blank line before the next paragraph is in
+	empty    bool // Is the innermost quote still empty?
+}
+
+// start tells that a new quote started.
+func (q *textQuotes) start() {
+	q.depth++
+	q.empty = true
+}
+
+// end tells that the innermost quote ended.
+func (q *textQuotes) end() {
+	if q.depth == 0 {
+		return
+	}
+
+	q.depth--
+	if q.sepDepth > q.depth {
+		q.sepDepth = q.depth
+	}
+	q.empty = false
+}
+
+// paragraph tells that a paragraph was written within the current quotes.
+func (q *textQuotes) paragraph() {
+	q.sepDepth = q.depth
+	q.empty = false
+}
+
+// prefix returns the prefix of the lines within the current quotes.
+func (q *textQuotes) prefix() string {
+	return strings.Repeat("> ", q.depth)
+}
+
+// separator returns the blank line (including its new line) that goes before
+// the next paragraph. Only the quotes enclosing both paragraphs it separates
+// are marked in it, so that sibling quotes are kept apart.
+func (q *textQuotes) separator() string {
+	return strings.TrimRight(strings.Repeat("> ", q.sepDepth), " ") + "\n"
+}
+
+// quote prefixes each of the lines of text (which must end with a new line)
+// with the markers of the current quotes.
+func (q *textQuotes) quote(text string) string {
+	if q.depth == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch line {
+		case "":
+		case "\n":
+			b.WriteString(strings.TrimRight(q.prefix(), " ") + line)
+		default:
+			b.WriteString(q.prefix() + line)
+		}
+	}
+
+	return b.String()
+}
+
+// NewTextRenderer creates a new TextRenderer that writes its output to w,
+// wrapping lines at 80 columns.
+func NewTextRenderer(w io.Writer) *TextRenderer {
+	return &TextRenderer{
+		Width: 80,
+		w:     w,
+	}
+}
+
+// Err returns the first error that happened while writing the output, or nil
+// if everything went fine.
+func (r *TextRenderer) Err() error {
+	return r.err
+}
+
+// StartDocument implements the Processor interface.
+func (r *TextRenderer) StartDocument() {
+	r.started = false
+	r.style = TextStyleRegular
+	r.links = nil
+	r.lists = nil
+	r.marker = ""
+	r.tightItem = false
+	r.quotes = textQuotes{}
+}
+
+// EndDocument implements the Processor interface.
+func (r *TextRenderer) EndDocument() {
+}
+
+// StartParagraph implements the Processor interface.
+func (r *TextRenderer) StartParagraph(parType ParType) {
+	r.segments = [][]textWord{nil}
+	r.word = nil
+	r.codeBlock = parType == ParTypeCodeBlock
+	r.code = ""
+}
+
+// EndParagraph implements the Processor interface.
+func (r *TextRenderer) EndParagraph(parType ParType) {
+	r.endWord()
+
+	if r.started && !r.tightItem {
+		r.write(r.quotes.separator())
+	}
+	r.started = true
+	r.tightItem = false
+	r.quotes.paragraph()
+
+	if r.codeBlock {
+		r.writeCodeBlock()
+		return
+	}
+
+	indent := r.contentIndent()
+	firstPrefix := strings.Repeat(" ", indent)
+	if r.marker != "" {
+		firstPrefix = strings.Repeat(" ", r.lists[len(r.lists)-1].indent) + r.marker
+	}
+
+	width := 0
+	if r.Width > 0 {
+		width = r.Width - indent - len(r.quotes.prefix())
+		if width < 1 {
+			width = 1
+		}
+	}
+
+	lines := wrapText(r.segments, width)
+
+	longest := 0
+	for i, line := range lines {
+		prefix := firstPrefix
+		if i > 0 {
+			prefix = strings.Repeat(" ", indent)
+		}
+		r.write(r.quotes.quote(prefix + r.formatLine(line, parType) + "\n"))
+
+		if n := lineWidth(line); n > longest {
+			longest = n
+		}
+	}
+
+	if level := parType.HeadingLevel(); level > 0 && r.ansi == nil {
+		underline := "-"
+		if level == 1 {
+			underline = "="
+		}
+		r.write(r.quotes.quote(strings.Repeat(underline, longest) + "\n"))
+	}
+
+	r.marker = ""
+	r.segments = nil
+}
+
+// Fragment implements the Processor interface.
+func (r *TextRenderer) Fragment(text string) {
+	text = strings.Map(replaceControl, text)
+
+	if r.codeBlock {
+		r.code += text
+		return
+	}
+
+	link := ""
+	if r.ansi != nil && r.ansi.Hyperlinks && len(r.links) > 0 {
+		link = r.links[len(r.links)-1]
+	}
+
+	r.word = append(r.word, textRun{text: text, style: r.style, link: link})
+}
+
+// SpecialToken implements the Processor interface.
+func (r *TextRenderer) SpecialToken(token SpecialToken) {
+	if token == SpecialTokenNonBreakingSpace {
+		r.Fragment(" ")
+		return
+	}
+
+	r.endWord()
+
+	if token == SpecialTokenLineBreak {
+		r.segments = append(r.segments, nil)
+	}
+}
+
+// ChangeTextStyle implements the Processor interface.
+func (r *TextRenderer) ChangeTextStyle(style TextStyle) {
+	r.style = style
+}
+
+// StartLink implements the Processor interface.
+func (r *TextRenderer) StartLink(target string) {
+	r.links = append(r.links, target)
+}
+
+// EndLink implements the Processor interface.
+func (r *TextRenderer) EndLink() {
+	if len(r.links) == 0 {
+		return
+	}
+
+	target := r.links[len(r.links)-1]
+	r.links = r.links[:len(r.links)-1]
+
+	if r.ansi != nil && r.ansi.Hyperlinks {
+		return
+	}
+
+	r.endWord()
+	r.word = textWord{{text: "<" + strings.Map(replaceControl, target) + ">", style: r.style}}
+}
+
+// StartList implements the ListProcessor interface.
+func (r *TextRenderer) StartList(list ListInfo) {
+	r.lists = append(r.lists, textList{
+		info:   list,
+		indent: r.contentIndent(),
+	})
+}
+
+// EndList implements the ListProcessor interface.
+func (r *TextRenderer) EndList(list ListInfo) {
+	if len(r.lists) > 0 {
+		r.lists = r.lists[:len(r.lists)-1]
+	}
+}
+
+// StartListItem implements the ListProcessor interface.
+func (r *TextRenderer) StartListItem(list ListInfo) {
+	if len(r.lists) == 0 {
+		return
+	}
+
+	l := &r.lists[len(r.lists)-1]
+
+	if l.info.Kind == ListKindOrdered {
+		r.marker = strconv.Itoa(l.info.Start+l.items) + ". "
+	} else {
+		r.marker = "- "
+	}
+
+	// Items of tight lists are not separated by blank lines, and neither are
+	// nested tight lists from the item they are nested in.
+	r.tightItem = l.info.Tight && (l.items > 0 || l.info.Depth > 1)
+
+	l.items++
+	l.textIndent = l.indent + utf8.RuneCountInString(r.marker)
+}
+
+// EndListItem implements the ListProcessor interface.
+func (r *TextRenderer) EndListItem(list ListInfo) {
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (r *TextRenderer) StartQuote() {
+	r.quotes.start()
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (r *TextRenderer) EndQuote() {
+	r.quotes.end()
+}
+
+// contentIndent returns the indentation of the contents of the innermost list
+// item we are in (or zero, if not in a list).
+func (r *TextRenderer) contentIndent() int {
+	if len(r.lists) == 0 {
+		return 0
+	}
+	return r.lists[len(r.lists)-1].textIndent
+}
+
+// endWord adds the word being built (if any) to the current paragraph.
+func (r *TextRenderer) endWord() {
+	if len(r.word) == 0 || r.segments == nil {
+		r.word = nil
+		return
+	}
+
+	last := len(r.segments) - 1
+	r.segments[last] = append(r.segments[last], r.word)
+	r.word = nil
+}
+
+// writeCodeBlock writes the current code block, and resets the state of the
+// paragraph.
+func (r *TextRenderer) writeCodeBlock() {
+	indent := strings.Repeat(" ", r.contentIndent()+textCodeIndent)
+	for _, line := range strings.Split(r.code, "\n") {
+		if line != "" {
+			line = indent + line
+		}
+		r.write(r.quotes.quote(line + "\n"))
+	}
+
+	r.codeBlock = false
+	r.code = ""
+	r.marker = ""
+	r.segments = nil
+}
+
+// formatLine returns the text of a line of a paragraph of a given type, ready
+// to be written.
+func (r *TextRenderer) formatLine(line []textWord, parType ParType) string {
+	if r.ansi != nil {
+		return r.ansi.formatLine(line, parType)
+	}
+
+	var s []string
+	for _, word := range line {
+		text := ""
+		for _, run := range word {
+			text += run.text
+		}
+		s = append(s, text)
+	}
+
+	return strings.Join(s, " ")
+}
+
+// write writes s to the output, unless a previous write failed.
+func (r *TextRenderer) write(s string) {
+	if r.err != nil || len(s) == 0 {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
+
+// replaceControl replaces control characters (but tabs and new lines) with
+// U+FFFD, for use with strings.Map. This keeps documents from sneaking escape
+// sequences into terminals.
+func replaceControl(r rune) rune {
+	if r != '\t' && r != '\n' && (r < 0x20 || r >= 0x7f && r <= 0x9f) {
+		return utf8.RuneError
+	}
+	return r
+}
+
+// wrapText wraps a paragraph, given as a list of words for each of its forced
+// lines, so that lines are at most width characters wide (unless a single
+// word is wider than that). If width is zero or less, lines are not wrapped.
+func wrapText(segments [][]textWord, width int) [][]textWord {
+	var lines [][]textWord
+
+	for _, words := range segments {
+		var line []textWord
+		lineLen := 0
+
+		for _, word := range words {
+			wordLen := wordWidth(word)
+
+			switch {
+			case len(line) == 0:
+				lineLen = wordLen
+			case width <= 0 || lineLen+1+wordLen <= width:
+				lineLen += 1 + wordLen
+			default:
+				lines = append(lines, line)
+				line = nil
+				lineLen = wordLen
+			}
+
+			line = append(line, word)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// wordWidth returns the width of a word, in characters.
+func wordWidth(word textWord) int {
+	n := 0
+	for _, run := range word {
+		n += utf8.RuneCountInString(run.text)
+	}
+	return n
+}
+
+// lineWidth returns the width of a line, in characters.
+func lineWidth(line []textWord) int {
+	n := 0
+	for i, word := range line {
+		if i > 0 {
+			n++
+		}
+		n += wordWidth(word)
+	}
+	return n
+}
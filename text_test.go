@@ -0,0 +1,70 @@
+package markydown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// renderText renders a Markydown document as plain text, wrapped at a given
+// width.
+func renderText(input string, width int) string {
+	var buf bytes.Buffer
+	r := NewTextRenderer(&buf)
+	r.Width = width
+	Parse(input, r)
+	return buf.String()
+}
+
+// Tests rendering plain text.
+func TestTextRenderer(t *testing.T) {
+	testData := map[string]string{
+		"": "",
+
+		// Wrapping
+		"The quick brown fox jumps over the lazy dog.":   "The quick brown\nfox jumps over\nthe lazy dog.\n",
+		"A  **very** *long*\nparagraph, just\\\nbroken.": "A very long\nparagraph, just\nbroken.\n",
+		"Non-breaking 100\\ kg and 200\\ kg":             "Non-breaking\n100 kg and\n200 kg\n",
+		"Unbreakable_words_may_be_too_long for it":       "Unbreakable_words_may_be_too_long\nfor it\n",
+
+		// Headings and paragraphs
+		"# Title\n\n## A longer subtitle\n\nText.": "Title\n=====\n\nA longer\nsubtitle\n--------\n\nText.\n",
+
+		// Code blocks
+		"+ Item\n\n```\nA very long line of code\n\n  indented\n```": "- Item\n\n" +
+			"    A very long line of code\n\n      indented\n",
+
+		// Quotes
+		"> The quick brown fox\n>\n> > jumps\n>\n> ```\n> a\n>\n> ```\n\n> Over": "> The quick\n> brown fox\n>\n" +
+			"> > jumps\n>\n>     a\n>\n\n> Over\n",
+		"# Heading\n\n> # Heading": "Heading\n=======\n\n> Heading\n> =======\n",
+
+		// Links
+		"Click [here](http://x.com).": "Click here\n<http://x.com>.\n",
+		"[Bell](\x07)\x1b":            "Bell <\ufffd>\ufffd\n",
+
+		// Lists
+		"+ One two three four\n+ Five": "- One two three\n  four\n- Five\n",
+		"Text\n\n+ One\n\n+ Two":       "Text\n\n- One\n\n- Two\n",
+		"9. Nine\n10. Ten ten ten ten\n    + Nested item here\n    + X\n\nText": "9. Nine\n" +
+			"10. Ten ten ten\n    ten\n    - Nested\n      item here\n    - X\n\nText\n",
+	}
+
+	for input, expected := range testData {
+		assert.Equal(t, renderText(input, 16), expected)
+	}
+}
+
+// Tests rendering plain text without wrapping.
+func TestTextRendererNoWrapping(t *testing.T) {
+	assert.Equal(t, renderText("The quick brown fox\njumps over the lazy dog.", 0),
+		"The quick brown fox jumps over the lazy dog.\n")
+}
+
+// Tests if write errors are reported.
+func TestTextRendererError(t *testing.T) {
+	r := NewTextRenderer(failingWriter{})
+	Parse("Some text", r)
+	assert.Equal(t, r.Err(), errWriteFailed)
+}
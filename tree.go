@@ -0,0 +1,624 @@
+package markydown
+
+// Node is a node in a Markydown document tree, as returned by ParseTree.
+//
+// The concrete types are all pointers to the structs defined below: *Document,
+// *Heading, *Paragraph, *CodeBlock, *Quote, *BulletList, *OrderedList,
+// *ListItem, *Emphasis, *Strong, *Link, *Text, *Code, *Image, *SoftSpace,
+// *NonBreakingSpace and *HardBreak.
+type Node interface {
+	isNode()
+}
+
+// Document is the root node of a document tree. Its children are the
+// document's headings, paragraphs, code blocks, quotes and lists.
+type Document struct {
+	Children []Node
+}
+
+// Heading is a heading. Its children are inline nodes.
+type Heading struct {
+	Level    int // Heading level, starting at 1
+	Children []Node
+}
+
+// Paragraph is a regular text paragraph. Its children are inline nodes.
+type Paragraph struct {
+	Children []Node
+}
+
+// CodeBlock is a fenced code block. It has no children.
+type CodeBlock struct {
+	Info string // The info string
+	Text string // The contents, verbatim
+}
+
+// Quote is a block quote. Its children are the headings, paragraphs, code
+// blocks, quotes and lists within it.
+type Quote struct {
+	Children []Node
+}
+
+// BulletList is a bulleted list. Its children are all *ListItems.
+type BulletList struct {
+	Tight    bool // Is this a tight list? (See ListInfo.Tight.)
+	Children []Node
+}
+
+// OrderedList is an ordered (numbered) list. Its children are all *ListItems.
+type OrderedList struct {
+	Start    int  // Number of the first item
+	Tight    bool // Is this a tight list? (See ListInfo.Tight.)
+	Children []Node
+}
+
+// ListItem is an item in a list. Its children are the inline nodes with the
+// item contents, optionally followed by nested lists.
+type ListItem struct {
+	Children []Node
+}
+
+// Emphasis is emphasized text. Its children are inline nodes.
+type Emphasis struct {
+	Children []Node
+}
+
+// Strong is strongly emphasized text. Its children are inline nodes.
+type Strong struct {
+	Children []Node
+}
+
+// Link is a link. Its children are inline nodes.
+type Link struct {
+	Target   string
+	Children []Node
+}
+
+// Text is a fragment of text.
+type Text struct {
+	Text string
+}
+
+// Code is a code span.
+type Code struct {
+	Text string
+}
+
+// Image is an image. It has no children.
+type Image struct {
+	Source string
+	Alt    string // The alternative text, as plain text
+}
+
+// SoftSpace is a regular space between words, that can be broken into a new
+// line if needed.
+type SoftSpace struct{}
+
+// NonBreakingSpace is a space that cannot be broken into a new line.
+type NonBreakingSpace struct{}
+
+// HardBreak is a hard line break.
+type HardBreak struct{}
+
+func (*Document) isNode()         {}
+func (*Heading) isNode()          {}
+func (*Paragraph) isNode()        {}
+func (*CodeBlock) isNode()        {}
+func (*Quote) isNode()            {}
+func (*BulletList) isNode()       {}
+func (*OrderedList) isNode()      {}
+func (*ListItem) isNode()         {}
+func (*Emphasis) isNode()         {}
+func (*Strong) isNode()           {}
+func (*Link) isNode()             {}
+func (*Text) isNode()             {}
+func (*Code) isNode()             {}
+func (*Image) isNode()            {}
+func (*SoftSpace) isNode()        {}
+func (*NonBreakingSpace) isNode() {}
+func (*HardBreak) isNode()        {}
+
+// ParseTree parses a Markydown document passed as a string and returns it as a
+// tree of Nodes.
+func ParseTree(document string, options ...Option) *Document {
+	b := &treeBuilder{}
+	Parse(document, b, options...)
+	return b.doc
+}
+
+// Walk walks the tree rooted at node and calls the Processor methods
+// corresponding to each node visited. This is a way to "replay" a document
+// tree into any Processor.
+//
+// The sequence of calls made for a tree returned by ParseTree is the same the
+// parser would make when parsing the original document. The exceptions are
+// documents with emphasis and links improperly nested (in which case Walk may
+// make some extra calls) and documents where a style change is immediately
+// followed by another one (in which case Walk will skip the intermediate
+// change).
+func Walk(node Node, processor Processor) {
+	w := &walker{processor: processor}
+	w.lister, _ = processor.(ListProcessor)
+	w.coder, _ = processor.(CodeProcessor)
+	w.quoter, _ = processor.(QuoteProcessor)
+	w.imager, _ = processor.(ImageProcessor)
+	w.walk(node)
+}
+
+//
+// Tree building
+//
+
+// openNode is a node that is being built by a treeBuilder.
+type openNode struct {
+	node     Node
+	children *[]Node // Where the node's children go
+}
+
+// treeBuilder is a Processor that builds a document tree.
+type treeBuilder struct {
+	doc      *Document  // The document being built
+	open     []openNode // Stack of nodes being built; the first is the document itself
+	parBase  int        // Length of open when the current paragraph started
+	style    TextStyle  // The current text style
+	codeInfo string     // Info string of the next code block
+	code     *CodeBlock // The code block being built, if any
+}
+
+// StartDocument implements the Processor interface.
+func (b *treeBuilder) StartDocument() {
+	b.doc = &Document{}
+	b.open = []openNode{{b.doc, &b.doc.Children}}
+	b.style = TextStyleRegular
+}
+
+// EndDocument implements the Processor interface.
+func (b *treeBuilder) EndDocument() {
+	b.open = nil
+}
+
+// StartParagraph implements the Processor interface.
+func (b *treeBuilder) StartParagraph(parType ParType) {
+	b.parBase = len(b.open)
+
+	if parType == ParTypeCodeBlock {
+		b.code = &CodeBlock{Info: b.codeInfo}
+		b.codeInfo = ""
+		b.add(b.code)
+		return
+	}
+
+	switch {
+	case isListParType(parType):
+		// The contents go directly into the ListItem
+
+	case parType.HeadingLevel() > 0:
+		heading := &Heading{Level: parType.HeadingLevel()}
+		b.push(heading, &heading.Children)
+
+	default:
+		par := &Paragraph{}
+		b.push(par, &par.Children)
+	}
+
+	// The parser closes styles at the end of each paragraph, but other callers
+	// (like ReplayJSON) may leave them open
+	b.openStyle(b.style)
+}
+
+// EndParagraph implements the Processor interface.
+func (b *treeBuilder) EndParagraph(parType ParType) {
+	b.code = nil
+
+	if b.parBase == 0 || b.parBase > len(b.open) {
+		return // No paragraph started within the document
+	}
+
+	b.dropEmpty(b.parBase)
+	b.open = b.open[:b.parBase]
+}
+
+// Fragment implements the Processor interface.
+func (b *treeBuilder) Fragment(text string) {
+	if b.code != nil {
+		b.code.Text += text
+		return
+	}
+
+	b.add(&Text{Text: text})
+}
+
+// Code implements the CodeProcessor interface.
+func (b *treeBuilder) Code(text string) {
+	b.add(&Code{Text: text})
+}
+
+// Image implements the ImageProcessor interface.
+func (b *treeBuilder) Image(source, alt string) {
+	b.add(&Image{Source: source, Alt: alt})
+}
+
+// CodeBlockInfo implements the CodeProcessor interface.
+func (b *treeBuilder) CodeBlockInfo(info string) {
+	b.codeInfo = info
+}
+
+// SpecialToken implements the Processor interface.
+func (b *treeBuilder) SpecialToken(token SpecialToken) {
+	switch token {
+	case SpecialTokenSpace:
+		b.add(&SoftSpace{})
+	case SpecialTokenNonBreakingSpace:
+		b.add(&NonBreakingSpace{})
+	case SpecialTokenLineBreak:
+		b.add(&HardBreak{})
+	}
+}
+
+// ChangeTextStyle implements the Processor interface.
+func (b *treeBuilder) ChangeTextStyle(style TextStyle) {
+	for i := len(b.open) - 1; i >= b.parBase; i-- {
+		switch b.open[i].node.(type) {
+		case *Emphasis:
+			if !style.Has(TextStyleEmphasis) {
+				b.close(i)
+			}
+		case *Strong:
+			if !style.Has(TextStyleStrong) {
+				b.close(i)
+			}
+		}
+	}
+
+	b.openStyle(style &^ b.style)
+	b.style = style
+}
+
+// StartList implements the ListProcessor interface.
+func (b *treeBuilder) StartList(list ListInfo) {
+	if list.Kind == ListKindOrdered {
+		l := &OrderedList{Start: list.Start, Tight: list.Tight}
+		b.push(l, &l.Children)
+	} else {
+		l := &BulletList{Tight: list.Tight}
+		b.push(l, &l.Children)
+	}
+}
+
+// EndList implements the ListProcessor interface.
+func (b *treeBuilder) EndList(list ListInfo) {
+	b.pop()
+}
+
+// StartListItem implements the ListProcessor interface.
+func (b *treeBuilder) StartListItem(list ListInfo) {
+	item := &ListItem{}
+	b.push(item, &item.Children)
+}
+
+// EndListItem implements the ListProcessor interface.
+func (b *treeBuilder) EndListItem(list ListInfo) {
+	b.pop()
+}
+
+// StartQuote implements the QuoteProcessor interface.
+func (b *treeBuilder) StartQuote() {
+	quote := &Quote{}
+	b.push(quote, &quote.Children)
+}
+
+// EndQuote implements the QuoteProcessor interface.
+func (b *treeBuilder) EndQuote() {
+	b.pop()
+}
+
+// StartLink implements the Processor interface.
+func (b *treeBuilder) StartLink(target string) {
+	link := &Link{Target: target}
+	b.push(link, &link.Children)
+}
+
+// EndLink implements the Processor interface.
+func (b *treeBuilder) EndLink() {
+	for i := len(b.open) - 1; i >= b.parBase; i-- {
+		if _, ok := b.open[i].node.(*Link); ok {
+			b.close(i)
+			return
+		}
+	}
+}
+
+// add adds a node as a child of the node currently being built. Nodes are
+// dropped if we are not within a document.
+func (b *treeBuilder) add(node Node) {
+	if len(b.open) == 0 {
+		return
+	}
+
+	children := b.open[len(b.open)-1].children
+	*children = append(*children, node)
+}
+
+// push adds a node as a child of the node currently being built, and makes it
+// the node currently being built. Nodes are dropped if we are not within a
+// document.
+func (b *treeBuilder) push(node Node, children *[]Node) {
+	if len(b.open) == 0 {
+		return
+	}
+
+	b.add(node)
+	b.open = append(b.open, openNode{node, children})
+}
+
+// pop stops building the node currently being built, unless it is the
+// document itself.
+func (b *treeBuilder) pop() {
+	if len(b.open) > 1 {
+		b.open = b.open[:len(b.open)-1]
+	}
+}
+
+// openStyle pushes nodes for each of the styles in a given text style.
+func (b *treeBuilder) openStyle(style TextStyle) {
+	for _, s := range textStyles {
+		if !style.Has(s) {
+			continue
+		}
+
+		switch s {
+		case TextStyleEmphasis:
+			n := &Emphasis{}
+			b.push(n, &n.Children)
+		case TextStyleStrong:
+			n := &Strong{}
+			b.push(n, &n.Children)
+		}
+	}
+}
+
+// close closes the open node with index i. Nodes opened after it (which happens
+// when links and emphasis are not properly nested) are closed too, and then
+// reopened as new nodes.
+func (b *treeBuilder) close(i int) {
+	reopen := b.open[i+1:]
+	b.dropEmpty(i)
+	b.open = b.open[:i]
+
+	for _, on := range reopen {
+		switch n := on.node.(type) {
+		case *Link:
+			b.StartLink(n.Target)
+		case *Emphasis:
+			b.openStyle(TextStyleEmphasis)
+		case *Strong:
+			b.openStyle(TextStyleStrong)
+		}
+	}
+}
+
+// dropEmpty removes from the tree the Emphasis and Strong nodes that are about
+// to be closed without any contents, from the innermost one down to the open
+// node with index i. These come from styles reopened by close just before
+// being closed themselves, and from style changes with no text in between.
+func (b *treeBuilder) dropEmpty(i int) {
+	for j := len(b.open) - 1; j >= i && j > 0; j-- {
+		switch n := b.open[j].node.(type) {
+		case *Emphasis:
+			if len(n.Children) > 0 {
+				return
+			}
+		case *Strong:
+			if len(n.Children) > 0 {
+				return
+			}
+		default:
+			return
+		}
+		parent := b.open[j-1].children
+		*parent = (*parent)[:len(*parent)-1]
+	}
+}
+
+//
+// Tree walking
+//
+
+// walker keeps the state needed to walk a document tree.
+type walker struct {
+	processor     Processor      // The Processor we are calling
+	lister        ListProcessor  // The processor, if it wants to know about lists; nil otherwise
+	coder         CodeProcessor  // The processor, if it wants to know about code spans; nil otherwise
+	quoter        QuoteProcessor // The processor, if it wants to know about block quotes; nil otherwise
+	imager        ImageProcessor // The processor, if it wants to know about images; nil otherwise
+	listDepth     int            // Current list nesting depth
+	listKind      ListKind       // Kind of the innermost list we are in
+	style         TextStyle      // The text style of the nodes being walked
+	reportedStyle TextStyle      // The text style last reported to the processor
+}
+
+// walk walks the tree rooted at a given node.
+func (w *walker) walk(node Node) {
+	switch n := node.(type) {
+	case *Document:
+		w.processor.StartDocument()
+		w.walkChildren(n.Children)
+		w.flushStyle()
+		w.processor.EndDocument()
+
+	case *Heading:
+		w.paragraph(headingParType(n.Level), n.Children)
+
+	case *Paragraph:
+		w.paragraph(ParTypeText, n.Children)
+
+	case *CodeBlock:
+		w.flushStyle()
+		if w.coder != nil {
+			w.coder.CodeBlockInfo(n.Info)
+		}
+		w.processor.StartParagraph(ParTypeCodeBlock)
+		if n.Text != "" {
+			w.processor.Fragment(n.Text)
+		}
+		w.processor.EndParagraph(ParTypeCodeBlock)
+
+	case *Quote:
+		if w.quoter != nil {
+			w.quoter.StartQuote()
+		}
+		w.walkChildren(n.Children)
+		if w.quoter != nil {
+			w.quoter.EndQuote()
+		}
+
+	case *BulletList:
+		w.list(ListInfo{Kind: ListKindBulleted, Tight: n.Tight}, n.Children)
+
+	case *OrderedList:
+		w.list(ListInfo{Kind: ListKindOrdered, Start: n.Start, Tight: n.Tight}, n.Children)
+
+	case *ListItem:
+		w.listItem(n.Children)
+
+	case *Emphasis:
+		w.walkStyle(TextStyleEmphasis, n.Children)
+
+	case *Strong:
+		w.walkStyle(TextStyleStrong, n.Children)
+
+	case *Link:
+		w.flushStyle()
+		w.processor.StartLink(n.Target)
+		w.walkChildren(n.Children)
+		w.flushStyle()
+		w.processor.EndLink()
+
+	case *Text:
+		w.flushStyle()
+		w.processor.Fragment(n.Text)
+
+	case *Code:
+		w.flushStyle()
+		if w.coder != nil {
+			w.coder.Code(n.Text)
+		} else {
+			w.processor.Fragment(n.Text)
+		}
+
+	case *Image:
+		w.flushStyle()
+		if w.imager != nil {
+			w.imager.Image(n.Source, n.Alt)
+		} else if n.Alt != "" {
+			w.processor.Fragment(n.Alt)
+		}
+
+	case *SoftSpace:
+		w.flushStyle()
+		w.processor.SpecialToken(SpecialTokenSpace)
+
+	case *NonBreakingSpace:
+		w.flushStyle()
+		w.processor.SpecialToken(SpecialTokenNonBreakingSpace)
+
+	case *HardBreak:
+		w.flushStyle()
+		w.processor.SpecialToken(SpecialTokenLineBreak)
+	}
+}
+
+// walkChildren walks a list of nodes.
+func (w *walker) walkChildren(children []Node) {
+	for _, child := range children {
+		w.walk(child)
+	}
+}
+
+// paragraph walks a paragraph of a given type.
+func (w *walker) paragraph(parType ParType, children []Node) {
+	w.flushStyle()
+	w.processor.StartParagraph(parType)
+	w.walkChildren(children)
+	w.flushStyle()
+	w.processor.EndParagraph(parType)
+}
+
+// list walks a list with a given list of items. Everything but the depth must
+// be set in info.
+func (w *walker) list(info ListInfo, items []Node) {
+	w.listDepth++
+	info.Depth = w.listDepth
+	w.listKind = info.Kind
+
+	if w.lister != nil {
+		w.lister.StartList(info)
+	}
+
+	for _, item := range items {
+		if w.lister != nil {
+			w.lister.StartListItem(info)
+		}
+		w.listKind = info.Kind
+		w.walk(item)
+		if w.lister != nil {
+			w.lister.EndListItem(info)
+		}
+	}
+
+	if w.lister != nil {
+		w.lister.EndList(info)
+	}
+
+	w.listDepth--
+}
+
+// listItem walks the children of a list item: a paragraph with the inline
+// nodes, followed by nested lists.
+func (w *walker) listItem(children []Node) {
+	i := 0
+	for i < len(children) && !isBlockNode(children[i]) {
+		i++
+	}
+
+	parType := ParTypeBulletedList
+	if w.listKind == ListKindOrdered {
+		parType = ParTypeOrderedList
+	}
+
+	w.paragraph(parType, children[:i])
+	w.walkChildren(children[i:])
+}
+
+// isBlockNode checks if a given node is a block node (as opposed to an inline
+// node).
+func isBlockNode(node Node) bool {
+	switch node.(type) {
+	case *Heading, *Paragraph, *CodeBlock, *Quote, *BulletList, *OrderedList, *ListItem:
+		return true
+	default:
+		return false
+	}
+}
+
+// walkStyle walks a node representing text in a given style (which is added to
+// the styles already in effect).
+//
+// Leaving a style is not reported immediately, so that moving directly from
+// one style to another doesn't generate a spurious change to the enclosing
+// style.
+func (w *walker) walkStyle(style TextStyle, children []Node) {
+	outer := w.style
+	w.style |= style
+	w.flushStyle()
+	w.walkChildren(children)
+	w.style = outer
+}
+
+// flushStyle tells the processor about any pending change of text style.
+func (w *walker) flushStyle() {
+	if w.style != w.reportedStyle {
+		w.reportedStyle = w.style
+		w.processor.ChangeTextStyle(w.style)
+	}
+}
@@ -0,0 +1,243 @@
+package markydown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lmbarros/sbxs_go_test/assert"
+)
+
+// Tests building document trees.
+func TestParseTree(t *testing.T) {
+	input := `# Title
+
+	Some *emphasized*, **strong**
+	and [*linked*](target) text\
+	here.
+
+	+ One
+
+	+ Two
+
+	1. Three
+	2. Four`
+
+	expected := &Document{Children: []Node{
+		&Heading{Level: 1, Children: []Node{&Text{"Title"}}},
+		&Paragraph{Children: []Node{
+			&Text{"Some"}, &SoftSpace{},
+			&Emphasis{Children: []Node{&Text{"emphasized"}}}, &Text{","}, &SoftSpace{},
+			&Strong{Children: []Node{&Text{"strong"}}}, &SoftSpace{},
+			&Text{"and"}, &SoftSpace{},
+			&Link{Target: "target", Children: []Node{
+				&Emphasis{Children: []Node{&Text{"linked"}}}}},
+			&SoftSpace{}, &Text{"text"}, &HardBreak{}, &Text{"here."}}},
+		&BulletList{Children: []Node{
+			&ListItem{Children: []Node{&Text{"One"}}},
+			&ListItem{Children: []Node{&Text{"Two"}}}}},
+		&OrderedList{Start: 1, Tight: true, Children: []Node{
+			&ListItem{Children: []Node{&Text{"Three"}}},
+			&ListItem{Children: []Node{&Text{"Four"}}}}},
+	}}
+
+	assert.Equal(t, ParseTree(input), expected)
+}
+
+// Tests building document trees with nested lists.
+func TestParseTreeNestedLists(t *testing.T) {
+	input := "+ One\n\n    2. *Two*\n\n+ Three"
+
+	expected := &Document{Children: []Node{
+		&BulletList{Children: []Node{
+			&ListItem{Children: []Node{
+				&Text{"One"},
+				&OrderedList{Start: 2, Tight: true, Children: []Node{
+					&ListItem{Children: []Node{
+						&Emphasis{Children: []Node{&Text{"Two"}}}}}}}}},
+			&ListItem{Children: []Node{&Text{"Three"}}}}},
+	}}
+
+	assert.Equal(t, ParseTree(input), expected)
+}
+
+// Tests building document trees with improperly nested links and emphasis.
+func TestParseTreeImproperNesting(t *testing.T) {
+	expected := &Document{Children: []Node{
+		&Paragraph{Children: []Node{
+			&Emphasis{Children: []Node{
+				&Text{"a"}, &SoftSpace{},
+				&Link{Target: "t", Children: []Node{&Text{"b"}}}}},
+			&Link{Target: "t", Children: []Node{&SoftSpace{}, &Text{"c"}}}}},
+	}}
+
+	assert.Equal(t, ParseTree("*a [b* c](t)"), expected)
+}
+
+// Tests building document trees with code spans.
+func TestParseTreeCode(t *testing.T) {
+	expected := &Document{Children: []Node{
+		&Paragraph{Children: []Node{
+			&Text{"Use"}, &SoftSpace{},
+			&Emphasis{Children: []Node{&Code{"*a*"}}}}},
+	}}
+
+	assert.Equal(t, ParseTree("Use *`*a*`*"), expected)
+}
+
+// Tests building document trees with code blocks.
+func TestParseTreeCodeBlock(t *testing.T) {
+	expected := &Document{Children: []Node{
+		&CodeBlock{Info: "go", Text: "x  :=\n\t*y*"},
+		&Paragraph{Children: []Node{&Text{"Text"}}},
+		&CodeBlock{},
+	}}
+
+	assert.Equal(t, ParseTree("```go\nx  :=\n\t*y*\n```\nText\n\n```\n```"), expected)
+}
+
+// Tests building document trees with block quotes.
+func TestParseTreeQuotes(t *testing.T) {
+	expected := &Document{Children: []Node{
+		&Quote{Children: []Node{
+			&Quote{Children: []Node{
+				&Paragraph{Children: []Node{&Text{"Nested"}}}}},
+			&BulletList{Tight: true, Children: []Node{
+				&ListItem{Children: []Node{&Text{"Item"}}}}}}},
+		&Quote{},
+	}}
+
+	assert.Equal(t, ParseTree("> > Nested\n>\n> + Item\n\n>"), expected)
+}
+
+// Tests building document trees with images.
+func TestParseTreeImages(t *testing.T) {
+	expected := &Document{Children: []Node{
+		&Paragraph{Children: []Node{
+			&Text{"See"}, &SoftSpace{}, &Image{Source: "c.png", Alt: "A cat"}}},
+	}}
+
+	assert.Equal(t, ParseTree("See ![A *cat*](c.png)"), expected)
+}
+
+// Tests building document trees from unbalanced calls, as ReplayJSON could
+// make.
+func TestTreeBuilderUnbalanced(t *testing.T) {
+	b := &treeBuilder{}
+	b.Fragment("Lost")
+	b.EndParagraph(ParTypeText)
+	b.EndQuote()
+
+	b.StartDocument()
+	b.EndList(ListInfo{})
+	b.EndListItem(ListInfo{})
+	b.StartParagraph(ParTypeText)
+	b.Fragment("Kept")
+	b.EndParagraph(ParTypeText)
+	b.EndParagraph(ParTypeText)
+	b.EndQuote()
+	b.Fragment("Too")
+	b.EndDocument()
+	b.Fragment("Lost")
+
+	expected := &Document{Children: []Node{
+		&Paragraph{Children: []Node{&Text{"Kept"}}},
+		&Text{"Too"},
+	}}
+
+	assert.Equal(t, b.doc, expected)
+}
+
+// Tests building document trees with combined text styles.
+func TestParseTreeCombinedStyles(t *testing.T) {
+	expected := &Document{Children: []Node{
+		&Paragraph{Children: []Node{
+			&Strong{Children: []Node{
+				&Text{"a"}, &SoftSpace{},
+				&Emphasis{Children: []Node{&Text{"b"}}}}},
+			&Emphasis{Children: []Node{&SoftSpace{}, &Text{"c"}}}}},
+	}}
+
+	assert.Equal(t, ParseTree("**a *b** c*"), expected)
+
+	// Styles closing on the same marker leave no empty nodes behind
+	expected = &Document{Children: []Node{
+		&Paragraph{Children: []Node{
+			&Strong{Children: []Node{
+				&Text{"strong"}, &SoftSpace{},
+				&Emphasis{Children: []Node{&Text{"both"}}}}}}},
+	}}
+
+	assert.Equal(t, ParseTree("**strong *both***"), expected)
+}
+
+// Tests if walking a document tree generates the same calls as parsing the
+// document.
+func TestWalk(t *testing.T) {
+	inputs := []string{
+		"",
+		"Just text.",
+		"# Unbe*lie*vable!",
+		"#### Four\n\n###### Six",
+		"*Switching**directly** to another style*",
+		"**Bold *and italic***, ***both** first*",
+		"*Unclosed **styles\n\nare closed",
+		"Non-breaking 100\\ kg",
+		"Some `code` and *`more`*",
+		"An ![*image*](x) and *![](y)*",
+		"*Text\n\n```go\nCode\n```\n\n+ **List",
+		"+ Click [here, *please*!](the*tárgeτ*)\n\n+ Or **not**",
+		"+ 1\n\n    + 1.1\n\n\t\t+ 1.1.1\n\n+ 2\n\nText\n\n+ 3",
+		"4. Four\n\n    1. Four.one\n\n    + Bullet\n\n5. Five",
+		"> # Quote\n>\n> > *Nested\n>\n> + Item\n>     + Nested\n\n>\n\nText",
+		"+ Tight\n    1. and\n    2. nested\n+ list",
+		`# The  title
+
+		Paragraph one.
+		Still the *same paragraph*.
+
+		## Subtitle
+
+		+ First;
+
+		+ [Second](http://www.example.com);
+
+		### Sub*sub*ti\*tle
+
+		Here \[we\] have some **more**   text\
+		with        some hard  \
+		breaks.`,
+	}
+
+	for _, input := range inputs {
+		expected := &testProcessor{}
+		Parse(input, expected)
+
+		actual := &testProcessor{}
+		Walk(ParseTree(input), actual)
+
+		assert.Equal(t, lastStyleChanges(actual.res), lastStyleChanges(expected.res))
+
+		expectedList := &listProcessor{}
+		Parse(input, expectedList)
+
+		actualList := &listProcessor{}
+		Walk(ParseTree(input), actualList)
+
+		assert.Equal(t, lastStyleChanges(actualList.res),
+			lastStyleChanges(expectedList.res))
+	}
+}
+
+// lastStyleChanges removes from a sequence of recorded calls the style changes
+// immediately followed by another style change, which Walk may skip.
+func lastStyleChanges(calls []string) []string {
+	res := []string{}
+	for i, call := range calls {
+		if strings.HasPrefix(call, "TS-") && i+1 < len(calls) &&
+			strings.HasPrefix(calls[i+1], "TS-") {
+			continue
+		}
+		res = append(res, call)
+	}
+	return res
+}
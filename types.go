@@ -4,6 +4,10 @@ package markydown
 //
 // The Markydown parser works kinda like in Template Method pattern: you call
 // the parser and it calls Processor's methods as it parses the data.
+//
+// ChangeTextStyle always receives the whole set of text styles in effect from
+// that point on (so, for example, closing the strong emphasis in
+// `***both** emphasized*` results in a call passing TextStyleEmphasis).
 type Processor interface {
 	StartDocument()
 	EndDocument()
@@ -37,27 +41,93 @@ const (
 
 	//ParTypeBulletedList is a bulleted list paragraph.
 	ParTypeBulletedList
+
+	// ParTypeHeading4 is a level-4 heading.
+	ParTypeHeading4
+
+	// ParTypeHeading5 is a level-5 heading.
+	ParTypeHeading5
+
+	// ParTypeHeading6 is a level-6 heading.
+	ParTypeHeading6
+
+	// ParTypeOrderedList is an ordered (numbered) list paragraph.
+	ParTypeOrderedList
+
+	// ParTypeCodeBlock is a fenced code block. Its contents are reported
+	// verbatim, in a single call to Fragment (or in no call at all, if the
+	// block is empty).
+	ParTypeCodeBlock
 )
 
+// maxHeadingLevel is the maximum heading level supported.
+const maxHeadingLevel = 6
+
+// headingParTypes maps heading levels to the corresponding paragraph types.
+var headingParTypes = [maxHeadingLevel + 1]ParType{
+	ParTypeInvalid,
+	ParTypeHeading1,
+	ParTypeHeading2,
+	ParTypeHeading3,
+	ParTypeHeading4,
+	ParTypeHeading5,
+	ParTypeHeading6,
+}
+
+// HeadingLevel returns the heading level (from 1 to 6) of a heading paragraph
+// type, or 0 if the paragraph type is not a heading.
+func (t ParType) HeadingLevel() int {
+	for level, parType := range headingParTypes {
+		if level > 0 && parType == t {
+			return level
+		}
+	}
+	return 0
+}
+
+// headingParType returns the paragraph type of a heading of a given level, or
+// ParTypeInvalid if the level is invalid.
+func headingParType(level int) ParType {
+	if level < 1 || level > maxHeadingLevel {
+		return ParTypeInvalid
+	}
+	return headingParTypes[level]
+}
+
 // TextStyle is a "semantic" style a text can be rendered in.
 //
 // By "semantic", I mean that this does not describe how the text is to be
 // physically rendered. For example, TextStyleEmphasis says that some fragment
 // of text is to be emphasized, but it doesn't tell if the text ig going to be
 // in italics, bold, in a different color, or something else.
+//
+// A TextStyle is a set of flags, so that styles can be combined. For example,
+// TextStyleEmphasis|TextStyleStrong is text that is both emphasized and
+// strongly emphasized. Use Has to check if a given style is part of the set.
 type TextStyle int
 
 const (
 	// TextStyleRegular represents regular text, without any kind of emphasis.
-	TextStyleRegular TextStyle = iota
+	TextStyleRegular TextStyle = 0
 
 	// TextStyleEmphasis represents emphasized text.
-	TextStyleEmphasis
+	TextStyleEmphasis TextStyle = 1 << 0
 
 	// TextStyleStrong represents strongly emphasized text.
-	TextStyleStrong
+	TextStyleStrong TextStyle = 1 << 1
 )
 
+// textStyles lists all the individual text styles that can be combined into a
+// TextStyle, in the order they shall be applied when more than one is in
+// effect.
+var textStyles = []TextStyle{TextStyleStrong, TextStyleEmphasis}
+
+// Has checks if the text style s includes the given style. (Every style
+// includes TextStyleRegular.)
+func (s TextStyle) Has(style TextStyle) bool {
+	return s&style == style
+}
+
 // SpecialToken is something that is not a text or paragraph and needs special
 // handling.
 type SpecialToken int
@@ -70,6 +140,10 @@ const (
 	// SpecialTokenLineBreak represents a hard line break within the same
 	// paragraph.
 	SpecialTokenLineBreak
+
+	// SpecialTokenNonBreakingSpace represents a space that shall not be
+	// broken into a new line, written as an escaped space (`\ `).
+	SpecialTokenNonBreakingSpace
 )
 
 // runeType represents a rune type.
@@ -81,7 +155,10 @@ const (
 	runeTypeEmphasis
 	runeTypeStrongEmphasis
 	runeTypeSpace
+	runeTypeNonBreakingSpace
 	runeTypeNewLine
 	runeTypeLinkStart
 	runeTypeLinkEnd
+	runeTypeCode
+	runeTypeImage
 )